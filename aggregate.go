@@ -0,0 +1,233 @@
+package gorm
+
+import (
+	"errors"
+	"fmt"
+	"gitops.sudytech.cn/guolei/gorm/driver"
+	"gitops.sudytech.cn/guolei/gorm/sys"
+	"strings"
+)
+
+const (
+	AggCount = "COUNT"
+	AggSum   = "SUM"
+	AggAvg   = "AVG"
+	AggMin   = "MIN"
+	AggMax   = "MAX"
+)
+
+// Agg 聚合字段，Name为*时仅COUNT可用，As为结果列名，可用于HAVING及排序
+type Agg struct {
+	Func string
+	Name string
+	As   string
+}
+
+func Count(name string, as string) Agg {
+	return Agg{Func: AggCount, Name: name, As: as}
+}
+
+func Sum(name string, as string) Agg {
+	return Agg{Func: AggSum, Name: name, As: as}
+}
+
+func Avg(name string, as string) Agg {
+	return Agg{Func: AggAvg, Name: name, As: as}
+}
+
+func Min(name string, as string) Agg {
+	return Agg{Func: AggMin, Name: name, As: as}
+}
+
+func Max(name string, as string) Agg {
+	return Agg{Func: AggMax, Name: name, As: as}
+}
+
+// GroupBy 分组查询，Columns为分组字段，Having中的字段可以是分组字段或聚合结果列名
+type GroupBy struct {
+	Columns []string
+	Aggs    []Agg
+	Having  []ConsWrapper
+}
+
+// GroupBy 分组字段，未指定Select时查询分组字段及聚合字段
+func (sb *SelectBuilder) GroupBy(names ...string) *SelectBuilder {
+	sb.groups = append(sb.groups, names...)
+	return sb
+}
+
+func (sb *SelectBuilder) Agg(aggs ...Agg) *SelectBuilder {
+	sb.aggs = append(sb.aggs, aggs...)
+	return sb
+}
+
+func (sb *SelectBuilder) Having(cons ...ConsWrapper) *SelectBuilder {
+	sb.having = append(sb.having, cons...)
+	return sb
+}
+
+func (sb *SelectBuilder) Distinct() *SelectBuilder {
+	sb.distinct = true
+	return sb
+}
+
+// aggColumns 聚合结果列解析，HAVING中替换为聚合表达式，排序中使用结果列名
+type aggColumns struct {
+	jc     *joinColumns
+	exprs  map[string]string
+	having bool
+}
+
+func (ac *aggColumns) quote(exp driver.Exp, name string) (string, sys.IGormErr) {
+	if expr, ok := ac.exprs[strings.ToLower(strings.TrimSpace(name))]; ok {
+		if ac.having {
+			return expr, nil
+		}
+		return exp.Quote(strings.TrimSpace(name)), nil
+	}
+	return ac.jc.quote(exp, name)
+}
+
+func (sb *SelectBuilder) genAggs(exp driver.Exp, jc *joinColumns) (map[string]string, sys.IGormErr) {
+	exprs := make(map[string]string)
+	for _, v := range sb.aggs {
+		fn := strings.ToUpper(v.Func)
+		switch fn {
+		case AggCount, AggSum, AggAvg, AggMin, AggMax:
+		default:
+			return nil, sys.NewMessage(sys.PropNotAllowCode, fmt.Sprintf("聚合函数%s不支持", v.Func))
+		}
+		if !regexAlias.MatchString(v.As) {
+			return nil, sys.NewMessage(sys.PropNotAllowCode, fmt.Sprintf("列名%q不合法", v.As))
+		}
+		if _, ok := exprs[strings.ToLower(v.As)]; ok {
+			return nil, sys.NewMessage(sys.PropNotAllowCode, fmt.Sprintf("列名%s重复", v.As))
+		}
+		if v.Name == "*" {
+			if fn != AggCount {
+				return nil, sys.NewMessage(sys.PropNotAllowCode, fmt.Sprintf("%s(*)不支持", fn))
+			}
+			exprs[strings.ToLower(v.As)] = "COUNT(*)"
+			continue
+		}
+		column, err := jc.quote(exp, v.Name)
+		if err != nil {
+			return nil, err
+		}
+		exprs[strings.ToLower(v.As)] = fmt.Sprintf("%s(%s)", fn, column)
+	}
+	return exprs, nil
+}
+
+// genGroup 生成 GROUP BY ... HAVING ... 部分及HAVING参数
+func (sb *SelectBuilder) genGroup(build *strings.Builder, exp driver.Exp, ac *aggColumns) ([]interface{}, sys.IGormErr) {
+	if len(sb.groups) > 0 {
+		build.WriteString(" GROUP BY ")
+		for k, v := range sb.groups {
+			column, err := ac.jc.quote(exp, v)
+			if err != nil {
+				return nil, err
+			}
+			if k > 0 {
+				build.WriteString(",")
+			}
+			build.WriteString(column)
+		}
+	}
+	if len(sb.having) == 0 {
+		return nil, nil
+	}
+	if len(sb.groups) == 0 && len(sb.aggs) == 0 {
+		return nil, sys.NewMessage(sys.PropNotAllowCode, "HAVING需要分组或聚合字段")
+	}
+	having, err := safeConsList(exp, &aggColumns{jc: ac.jc, exprs: ac.exprs, having: true}, sb.having)
+	if err != nil {
+		return nil, err
+	}
+	build.WriteString(" HAVING 1=1")
+	return GenDbWhereSQL(build, exp.DbType, having), nil
+}
+
+func (sb *SelectBuilder) grouped() bool {
+	return sb.distinct || len(sb.groups) > 0 || len(sb.having) > 0
+}
+
+// GenCountSQL 根据查询SQL生成总数SQL，包含WITH(CTE)、DISTINCT、GROUP BY、UNION、LIMIT等时包装为子查询，否则替换顶层FROM之前的查询字段
+func GenCountSQL(sql string) string {
+	sql = strings.TrimSpace(sql)
+	from, wrap := scanSelectSQL(sql)
+	if wrap || from == -1 {
+		return fmt.Sprintf("SELECT COUNT(*) FROM (%s) c", sql)
+	}
+	return "SELECT COUNT(1) " + sql[from:]
+}
+
+var countWrapWords = map[string]bool{
+	"DISTINCT": true, "GROUP": true, "HAVING": true, "UNION": true, "INTERSECT": true, "EXCEPT": true, "MINUS": true,
+	"ORDER": true, "LIMIT": true, "OFFSET": true, "FETCH": true, "TOP": true, "WITH": true,
+}
+
+// scanSelectSQL 跳过括号及引号内容，返回顶层FROM的位置及是否需要包装为子查询
+func scanSelectSQL(sql string) (int, bool) {
+	from := -1
+	wrap := false
+	scanTopWords(sql, func(word string, pos int) bool {
+		if word == "FROM" && from == -1 {
+			from = pos
+		} else if countWrapWords[word] {
+			wrap = true
+		}
+		return !wrap
+	})
+	return from, wrap
+}
+
+// scanTopWords 按顺序遍历不在括号及引号内的单词(大写)，fn返回false时停止
+func scanTopWords(sql string, fn func(word string, pos int) bool) {
+	depth := 0
+	var quote byte
+	for i := 0; i < len(sql); i++ {
+		c := sql[i]
+		if quote != 0 {
+			if c == quote {
+				quote = 0
+			}
+			continue
+		}
+		switch {
+		case c == '\'' || c == '"' || c == '`':
+			quote = c
+		case c == '(':
+			depth++
+		case c == ')':
+			depth--
+		case isWordByte(c) && (i == 0 || !isWordByte(sql[i-1])):
+			j := i
+			for j < len(sql) && isWordByte(sql[j]) {
+				j++
+			}
+			if depth == 0 && !fn(strings.ToUpper(sql[i:j]), i) {
+				return
+			}
+			i = j - 1
+		}
+	}
+}
+
+func isWordByte(c byte) bool {
+	return c == '_' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+// FindPageGroupList 单表分组分页查询，字段按模型白名单校验，表别名为t
+func (gm *Gorm) FindPageGroupList(model interface{}, pageNo int32, pageSize int32, group GroupBy, cons []ConsWrapper, orders []QueryOrder) ([]Row, int64, error) {
+	if len(group.Columns) == 0 && len(group.Aggs) == 0 {
+		return nil, 0, errors.New("分组查询需要分组或聚合字段")
+	}
+	return gm.From(model, "t").
+		GroupBy(group.Columns...).
+		Agg(group.Aggs...).
+		Having(group.Having...).
+		Where(cons...).
+		OrderBy(orders...).
+		Page(pageNo, pageSize)
+}
@@ -0,0 +1,55 @@
+package gorm
+
+import (
+	"gitops.sudytech.cn/guolei/gorm/driver"
+	"gitops.sudytech.cn/guolei/gorm/testdata"
+	"testing"
+)
+
+func TestSelectBuilder_GroupBy(t *testing.T) {
+	gm := &Gorm{Option: &Option{DbType: driver.DBTypeUxDB}}
+	rows, count, params, err := gm.From(&testdata.Algorithm{}, "t").
+		Where(Eq("enable", 1)).
+		GroupBy("type").
+		Agg(Count("*", "total"), Sum("sort", "sortSum")).
+		Having(Gt("total", 1)).
+		Desc("total").
+		SQL()
+	if err != nil {
+		t.Fatalf("sql.err=%v", err.GetMessage())
+	}
+	body := `SELECT "t"."type",COUNT(*) AS "total",SUM("t"."sort") AS "sortSum" FROM "T_TEST_ALGORITHM" "t" WHERE 1=1 AND ("t"."enable" = ?) GROUP BY "t"."type" HAVING 1=1 AND (COUNT(*) > ?)`
+	if expect := body + ` ORDER BY "total" DESC`; rows != expect {
+		t.Fatalf("rows=%s,expect=%s", rows, expect)
+	}
+	if expect := `SELECT COUNT(*) FROM (` + body + `) c`; count != expect {
+		t.Fatalf("count=%s,expect=%s", count, expect)
+	}
+	if len(params) != 2 {
+		t.Fatalf("params=%v", params)
+	}
+	if _, _, _, err = gm.From(&testdata.Algorithm{}, "t").Agg(Sum("*", "s")).SQL(); err == nil {
+		t.Fatalf("sum(*) must be rejected")
+	}
+	if _, _, _, err = gm.From(&testdata.Algorithm{}, "t").Having(Gt("sort", 1)).SQL(); err == nil {
+		t.Fatalf("having without group must be rejected")
+	}
+}
+
+func TestGenCountSQL(t *testing.T) {
+	testdatas := map[string]string{
+		"SELECT t.*,(SELECT COUNT(1) FROM b WHERE b.a=t.id) c FROM a t WHERE 1=1": "SELECT COUNT(1) FROM a t WHERE 1=1",
+		"select id from a where name='group by'":                                  "SELECT COUNT(1) from a where name='group by'",
+		"SELECT DISTINCT type FROM a":                                             "SELECT COUNT(*) FROM (SELECT DISTINCT type FROM a) c",
+		"SELECT type,COUNT(1) FROM a GROUP BY type":                               "SELECT COUNT(*) FROM (SELECT type,COUNT(1) FROM a GROUP BY type) c",
+		"SELECT id FROM a UNION SELECT id FROM b":                                 "SELECT COUNT(*) FROM (SELECT id FROM a UNION SELECT id FROM b) c",
+		"SELECT * FROM (SELECT id FROM a GROUP BY id) x":                          "SELECT COUNT(1) FROM (SELECT id FROM a GROUP BY id) x",
+		"WITH x AS (SELECT id FROM a) SELECT * FROM x":                            "SELECT COUNT(*) FROM (WITH x AS (SELECT id FROM a) SELECT * FROM x) c",
+		"with recursive x(id) AS (SELECT 1) SELECT id FROM x":                     "SELECT COUNT(*) FROM (with recursive x(id) AS (SELECT 1) SELECT id FROM x) c",
+	}
+	for k, v := range testdatas {
+		if got := GenCountSQL(k); got != v {
+			t.Fatalf("sql=%s,count=%s,expect=%s", k, got, v)
+		}
+	}
+}
@@ -0,0 +1,362 @@
+package gorm
+
+import (
+	"context"
+	"database/sql"
+	sqldriver "database/sql/driver"
+	"errors"
+	"github.com/go-sql-driver/mysql"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/stdlib"
+	"github.com/samber/lo"
+	"gitops.sudytech.cn/guolei/gorm/driver"
+	"go.uber.org/zap"
+	gmysql "gorm.io/driver/mysql"
+	"gorm.io/gorm"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// BatchOptions 批量执行选项，作用于 TranSQL、TransactionSQL
+type BatchOptions struct {
+	// Size 连续的相同单行INSERT合并为多行VALUES的最大行数，同时受 driver.GetMaxParams 限制，小于2时不合并
+	Size int
+	// MaxStatements 一次发送的最大语句数，默认1000
+	MaxStatements int
+}
+
+// WithBatch 返回批量执行的连接：连续的相同单行INSERT合并为多行VALUES，之后多条语句一次发送：
+//   - 优炫、海量不在事务中时固定一个连接开启事务，通过pgx批量协议发送，语句不经过gorm回调及日志，出错时只记录zap日志；
+//   - MySQL连接开启 multiStatements 及 interpolateParams (Option.MultiStatements) 时，INSERT/UPDATE/DELETE等以分号连接后发送；
+//   - 达梦将INSERT/UPDATE/DELETE等包装为匿名块 BEGIN ...; END; 发送。
+//
+// 分组发送时出错只能定位到所在的组；MySQL未开启多语句时逐条执行
+//
+//	err := gm.WithBatch(BatchOptions{Size: 500}).TranSQL(sqls)
+func (gm *Gorm) WithBatch(opts BatchOptions) *Gorm {
+	if opts.MaxStatements <= 0 {
+		opts.MaxStatements = 1000
+	}
+	return &Gorm{DB: gm.DB, Option: gm.Option, retry: gm.retry, batch: &opts}
+}
+
+var errNotPgx = errors.New("connection is not pgx")
+
+// tranSQL 事务中执行sql后执行fc
+func (gm *Gorm) tranSQL(exp driver.Exp, sql []TranSQL, fc func(tx *gorm.DB) error) error {
+	if gm.batch == nil || gm.InTransaction() || (exp.DbType != driver.DBTypeUxDB && exp.DbType != driver.DBTypeVbDB) {
+		return gm.transaction(func(tx *gorm.DB) error {
+			if err := gm.execTranSQL(tx, nil, exp, sql); err != nil {
+				return err
+			}
+			return fc(tx)
+		})
+	}
+	ctx := gm.context()
+	sqlDB, err := gm.DB.DB()
+	if err != nil {
+		return err
+	}
+	conn, err := sqlDB.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	db := gm.DB.Session(&gorm.Session{NewDB: true, Context: ctx})
+	db.Statement.ConnPool = conn
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := gm.execTranSQL(tx, conn, exp, sql); err != nil {
+			return err
+		}
+		return fc(tx)
+	})
+}
+
+// execTranSQL 在事务中执行sql，可分组的语句按 groupExec 一次发送
+func (gm *Gorm) execTranSQL(tx *gorm.DB, conn *sql.Conn, exp driver.Exp, sql []TranSQL) error {
+	if gm.batch == nil {
+		return execEach(tx, exp, sql)
+	}
+	sql = CoalesceInsert(sql, gm.batch.Size, driver.GetMaxParams(exp.DbType))
+	exec, groupable := groupExec(tx, conn, exp)
+	if exec == nil {
+		return execEach(tx, exp, sql)
+	}
+	var pending []TranSQL
+	flush := func() error {
+		if len(pending) == 0 {
+			return nil
+		}
+		batch := pending
+		pending = nil
+		if len(batch) == 1 {
+			return execEach(tx, exp, batch)
+		}
+		err := exec(batch)
+		if !errors.Is(err, errNotPgx) {
+			return err
+		}
+		exec = nil
+		return execEach(tx, exp, batch)
+	}
+	for k, v := range sql {
+		if exec != nil && groupable(v) {
+			pending = append(pending, v)
+			if len(pending) >= gm.batch.MaxStatements {
+				if err := flush(); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+		if err := flush(); err != nil {
+			return err
+		}
+		if err := execEach(tx, exp, sql[k:k+1]); err != nil {
+			return err
+		}
+	}
+	return flush()
+}
+
+// groupExec 按数据库类型返回分组执行函数及可分组的语句，不支持分组时返回nil
+func groupExec(tx *gorm.DB, conn *sql.Conn, exp driver.Exp) (func([]TranSQL) error, func(TranSQL) bool) {
+	switch exp.DbType {
+	case driver.DBTypeUxDB, driver.DBTypeVbDB:
+		if conn == nil {
+			return nil, nil
+		}
+		return func(sql []TranSQL) error {
+				return execPgBatch(tx.Statement.Context, conn, exp, sql)
+			}, func(v TranSQL) bool {
+				return pgBatchable(v.Params)
+			}
+	case driver.DBTypeDmDB:
+		return func(sql []TranSQL) error {
+			return execGroup(tx, exp, sql, "BEGIN\n", ";\n", ";\nEND;")
+		}, groupableDML
+	default:
+		if !mysqlMultiStatements(tx) {
+			return nil, nil
+		}
+		return func(sql []TranSQL) error {
+			return execGroup(tx, exp, sql, "", ";", "")
+		}, groupableDML
+	}
+}
+
+// execGroup 将多条语句按prefix、sep、suffix拼接为一条执行，参数依次合并
+func execGroup(tx *gorm.DB, exp driver.Exp, sql []TranSQL, prefix string, sep string, suffix string) error {
+	var build strings.Builder
+	var params []interface{}
+	build.WriteString(prefix)
+	for k, v := range sql {
+		build.WriteString(lo.Ternary(k == 0, "", sep))
+		build.WriteString(strings.TrimRight(strings.TrimSpace(exp.ExecSQL(v.SQL)), ";"))
+		params = append(params, v.Params...)
+	}
+	build.WriteString(suffix)
+	if err := tx.Exec(build.String(), params...).Error; err != nil {
+		zap.S().Errorf("tran group sql:sql=%s,param=%v,err=%v", build.String(), params, err)
+		return err
+	}
+	return nil
+}
+
+// groupableDML 可分组发送的语句，只允许INSERT、UPDATE、DELETE、MERGE、REPLACE
+func groupableDML(v TranSQL) bool {
+	sql := strings.ToUpper(strings.TrimSpace(v.SQL))
+	return lo.ContainsBy([]string{"INSERT ", "UPDATE ", "DELETE ", "MERGE ", "REPLACE "}, func(prefix string) bool {
+		return strings.HasPrefix(sql, prefix)
+	})
+}
+
+// mysqlMultiStatements MySQL连接是否开启 multiStatements 及 interpolateParams，否则带参数的多语句无法执行
+func mysqlMultiStatements(tx *gorm.DB) bool {
+	d, ok := tx.Dialector.(*gmysql.Dialector)
+	if !ok || d.Config == nil || d.DSN == "" {
+		return false
+	}
+	cfg, err := mysql.ParseDSN(d.DSN)
+	return err == nil && cfg.MultiStatements && cfg.InterpolateParams
+}
+
+func execEach(tx *gorm.DB, exp driver.Exp, sql []TranSQL) error {
+	for _, v := range sql {
+		nSQL := exp.ExecSQL(v.SQL)
+		if err := tx.Exec(nSQL, v.Params...).Error; err != nil {
+			zap.S().Errorf("tran sql:src=%s,new=%s,param=%v,err=%v", v.SQL, nSQL, v.Params, err)
+			return err
+		}
+	}
+	return nil
+}
+
+// execPgBatch 通过pgx批量协议在conn当前事务中执行，不经过gorm回调及日志，conn不是pgx连接时返回 errNotPgx
+func execPgBatch(ctx context.Context, conn *sql.Conn, exp driver.Exp, sql []TranSQL) error {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return conn.Raw(func(dc interface{}) error {
+		sc, ok := dc.(*stdlib.Conn)
+		if !ok {
+			return errNotPgx
+		}
+		batch := &pgx.Batch{}
+		nSQLs := make([]string, len(sql))
+		for k, v := range sql {
+			nSQLs[k] = exp.ExecSQL(v.SQL)
+			batch.Queue(PgBindVars(nSQLs[k]), v.Params...)
+		}
+		br := sc.Conn().SendBatch(ctx, batch)
+		for k, v := range sql {
+			if _, err := br.Exec(); err != nil {
+				zap.S().Errorf("tran batch sql:src=%s,new=%s,param=%v,err=%v", v.SQL, nSQLs[k], v.Params, err)
+				br.Close()
+				return err
+			}
+		}
+		return br.Close()
+	})
+}
+
+// pgBatchable 参数是否可直接交给pgx，切片(IN展开)、子查询等需要gorm处理的参数返回false
+func pgBatchable(params []interface{}) bool {
+	for _, v := range params {
+		switch v.(type) {
+		case nil, sqldriver.Valuer, time.Time, []byte:
+			continue
+		}
+		rv := reflect.ValueOf(v)
+		if rv.Kind() == reflect.Ptr {
+			if rv.IsNil() {
+				continue
+			}
+			rv = rv.Elem()
+		}
+		switch rv.Kind() {
+		case reflect.Bool, reflect.String, reflect.Float32, reflect.Float64,
+			reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+			reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		default:
+			if _, ok := rv.Interface().(time.Time); !ok {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// PgBindVars 将?占位符替换为$1、$2...，忽略字符串、带引号标识符及注释中的?
+func PgBindVars(sql string) string {
+	var build strings.Builder
+	n := 0
+	for i := 0; i < len(sql); i++ {
+		c := sql[i]
+		switch c {
+		case '\'', '"':
+			end := strings.IndexByte(sql[i+1:], c)
+			if end == -1 {
+				build.WriteString(sql[i:])
+				return build.String()
+			}
+			build.WriteString(sql[i : i+end+2])
+			i += end + 1
+		case '-':
+			if i+1 < len(sql) && sql[i+1] == '-' {
+				end := strings.IndexByte(sql[i:], '\n')
+				if end == -1 {
+					build.WriteString(sql[i:])
+					return build.String()
+				}
+				build.WriteString(sql[i : i+end])
+				i += end - 1
+			} else {
+				build.WriteByte(c)
+			}
+		case '?':
+			n++
+			build.WriteString("$" + strconv.Itoa(n))
+		default:
+			build.WriteByte(c)
+		}
+	}
+	return build.String()
+}
+
+// CoalesceInsert 将连续的SQL相同、参数个数相同的单行 INSERT ... VALUES(...) 合并为多行VALUES，
+// 每条最多size行且参数个数不超过maxParams，size小于2时原样返回
+func CoalesceInsert(sql []TranSQL, size int, maxParams int) []TranSQL {
+	if size < 2 {
+		return sql
+	}
+	var resp []TranSQL
+	for i := 0; i < len(sql); {
+		j := i + 1
+		head, tuple, ok := splitInsertValues(sql[i].SQL)
+		if ok {
+			n, limit := len(sql[i].Params), size
+			if maxParams > 0 && n > 0 && maxParams/n < limit {
+				limit = maxParams / n
+			}
+			for j < len(sql) && j-i < limit && sql[j].SQL == sql[i].SQL && len(sql[j].Params) == n {
+				j++
+			}
+		}
+		if j-i == 1 {
+			resp = append(resp, sql[i])
+			i++
+			continue
+		}
+		var build strings.Builder
+		var params []interface{}
+		build.WriteString(head)
+		for k := i; k < j; k++ {
+			if k > i {
+				build.WriteString(",")
+			}
+			build.WriteString(tuple)
+			params = append(params, sql[k].Params...)
+		}
+		resp = append(resp, TranSQL{SQL: build.String(), Params: params})
+		i = j
+	}
+	return resp
+}
+
+// splitInsertValues 拆分单行INSERT为 INSERT ... VALUES 及 (...) 两部分，VALUES后还有其它子句时返回false
+func splitInsertValues(sql string) (string, string, bool) {
+	sql = strings.TrimSpace(sql)
+	uSQL := strings.ToUpper(sql)
+	if !strings.HasPrefix(uSQL, "INSERT INTO ") {
+		return "", "", false
+	}
+	i := strings.LastIndex(uSQL, "VALUES")
+	if i == -1 {
+		return "", "", false
+	}
+	head, tuple := sql[0:i+6], strings.TrimSpace(sql[i+6:])
+	if !strings.HasPrefix(tuple, "(") {
+		return "", "", false
+	}
+	depth := 0
+	for k := 0; k < len(tuple); k++ {
+		switch tuple[k] {
+		case '\'':
+			end := strings.IndexByte(tuple[k+1:], '\'')
+			if end == -1 {
+				return "", "", false
+			}
+			k += end + 1
+		case '(':
+			depth++
+		case ')':
+			if depth--; depth == 0 {
+				return head, tuple, k == len(tuple)-1
+			}
+		}
+	}
+	return "", "", false
+}
@@ -0,0 +1,126 @@
+package gorm
+
+import (
+	"database/sql"
+	"gitops.sudytech.cn/guolei/gorm/driver"
+	gmysql "gorm.io/driver/mysql"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCoalesceInsert(t *testing.T) {
+	insert := "INSERT INTO T_TEST(id,name) VALUES(?,?)"
+	sqls := []TranSQL{
+		{SQL: insert, Params: []interface{}{1, "a"}},
+		{SQL: insert, Params: []interface{}{2, "b"}},
+		{SQL: insert, Params: []interface{}{3, "c"}},
+		{SQL: "UPDATE T_TEST SET name=? WHERE id=?", Params: []interface{}{"d", 1}},
+		{SQL: insert, Params: []interface{}{4, "e"}},
+		{SQL: "INSERT INTO T_TEST(id,name) VALUES(?,?) ON DUPLICATE KEY UPDATE name=?", Params: []interface{}{5, "f", "f"}},
+		{SQL: "INSERT INTO T_TEST(id,name) VALUES(?,?) ON DUPLICATE KEY UPDATE name=?", Params: []interface{}{6, "g", "g"}},
+	}
+	resp := CoalesceInsert(sqls, 2, 0)
+	if len(resp) != 6 {
+		t.Fatalf("resp=%v", resp)
+	}
+	if resp[0].SQL != "INSERT INTO T_TEST(id,name) VALUES(?,?),(?,?)" || len(resp[0].Params) != 4 || resp[0].Params[2] != 2 {
+		t.Fatalf("sql=%s,params=%v", resp[0].SQL, resp[0].Params)
+	}
+	if resp[1].SQL != insert || resp[3].SQL != insert {
+		t.Fatalf("resp=%v", resp)
+	}
+	if resp = CoalesceInsert(sqls, 100, 5); len(resp) != 6 || len(resp[0].Params) != 4 {
+		t.Fatalf("max params:resp=%v", resp)
+	}
+	if resp = CoalesceInsert(sqls, 100, 0); len(resp) != 5 || len(resp[0].Params) != 6 {
+		t.Fatalf("size:resp=%v", resp)
+	}
+	if resp = CoalesceInsert(sqls, 1, 0); len(resp) != len(sqls) {
+		t.Fatalf("disabled:resp=%v", resp)
+	}
+	literal := "INSERT INTO T_TEST(id,name) VALUES(?,'a)b')"
+	resp = CoalesceInsert([]TranSQL{{SQL: literal, Params: []interface{}{1}}, {SQL: literal, Params: []interface{}{2}}}, 10, 0)
+	if len(resp) != 1 || resp[0].SQL != "INSERT INTO T_TEST(id,name) VALUES(?,'a)b'),(?,'a)b')" {
+		t.Fatalf("literal:resp=%v", resp)
+	}
+}
+
+func TestPgBindVars(t *testing.T) {
+	src := "UPDATE \"T_TEST\" SET \"name\"=?,\"note\"='why?' WHERE \"id\"=? -- is ?\n AND \"a?\"=?"
+	expect := "UPDATE \"T_TEST\" SET \"name\"=$1,\"note\"='why?' WHERE \"id\"=$2 -- is ?\n AND \"a?\"=$3"
+	if nSQL := PgBindVars(src); nSQL != expect {
+		t.Fatalf("sql=%s,expect=%s", nSQL, expect)
+	}
+}
+
+func TestPgBatchable(t *testing.T) {
+	name := "a"
+	if !pgBatchable([]interface{}{nil, 1, "a", &name, time.Now(), []byte("a"), sql.NullString{}}) {
+		t.Fatalf("basic params must be batchable")
+	}
+	if pgBatchable([]interface{}{[]int{1, 2}}) || pgBatchable([]interface{}{map[string]int{}}) {
+		t.Fatalf("slice and map params must not be batchable")
+	}
+}
+
+func TestGorm_TranSQLBatch(t *testing.T) {
+	insert := "INSERT INTO T_TEST(id,name) VALUES(?,?)"
+	sqls := []TranSQL{
+		{SQL: insert, Params: []interface{}{1, "a"}},
+		{SQL: insert, Params: []interface{}{2, "b"}},
+		{SQL: "DELETE FROM T_TEST WHERE id IN ?", Params: []interface{}{[]int{3, 4}}},
+		{SQL: insert, Params: []interface{}{5, "c"}},
+	}
+	for _, dbType := range []int{driver.DBTypeMySQL, driver.DBTypeUxDB} {
+		gm, c := fakeGorm(t, nil)
+		gm.Option.DbType = dbType
+		if err := gm.WithBatch(BatchOptions{Size: 10}).TranSQL(sqls); err != nil {
+			t.Fatalf("dbType=%d,err=%v", dbType, err)
+		}
+		// 非pgx连接时退回逐条执行，顺序不变
+		stmts := c.statements()
+		if len(stmts) != 5 || stmts[0] != "BEGIN" || stmts[4] != "COMMIT" {
+			t.Fatalf("dbType=%d,stmts=%v", dbType, stmts)
+		}
+		if !strings.Contains(stmts[1], "VALUES(?,?),(?,?)") || !strings.Contains(stmts[2], "IN (?,?)") || !strings.HasPrefix(stmts[3], "INSERT") {
+			t.Fatalf("dbType=%d,stmts=%v", dbType, stmts)
+		}
+	}
+}
+
+func TestGorm_TranSQLBatchGroup(t *testing.T) {
+	sqls := []TranSQL{
+		{SQL: "INSERT INTO T_TEST(id,name) VALUES(?,?)", Params: []interface{}{1, "a"}},
+		{SQL: "UPDATE T_TEST SET name=? WHERE id=?;", Params: []interface{}{"b", 2}},
+		{SQL: "CREATE INDEX IDX_TEST ON T_TEST(name)"},
+		{SQL: "DELETE FROM T_TEST WHERE id=?", Params: []interface{}{3}},
+	}
+	// MySQL开启multiStatements时以分号连接发送，DDL单独执行
+	c := &fakeConnector{}
+	dsn := "u:p@tcp(127.0.0.1:3306)/db?multiStatements=true&interpolateParams=true"
+	db, err := gorm.Open(gmysql.New(gmysql.Config{DSN: dsn, Conn: sql.OpenDB(c), SkipInitializeWithVersion: true}), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatalf("open.err=%v", err)
+	}
+	gm := &Gorm{DB: db, Option: &Option{DbType: driver.DBTypeMySQL}}
+	if err = gm.WithBatch(BatchOptions{}).TranSQL(sqls); err != nil {
+		t.Fatalf("mysql.err=%v", err)
+	}
+	stmts := c.statements()
+	if len(stmts) != 5 || stmts[1] != "INSERT INTO T_TEST(id,name) VALUES(?,?);UPDATE T_TEST SET name=? WHERE id=?" || !strings.HasPrefix(stmts[2], "CREATE") {
+		t.Fatalf("mysql.stmts=%v", stmts)
+	}
+	// 达梦包装为匿名块
+	gm, c = fakeGorm(t, nil)
+	gm.Option.DbType = driver.DBTypeDmDB
+	if err = gm.WithBatch(BatchOptions{}).TranSQL(append(sqls[:2:2], sqls[3])); err != nil {
+		t.Fatalf("dm.err=%v", err)
+	}
+	stmts = c.statements()
+	if len(stmts) != 3 || !strings.HasPrefix(stmts[1], "BEGIN\n") || !strings.HasSuffix(stmts[1], ";\nEND;") || strings.Count(stmts[1], ";\n") != 3 {
+		t.Fatalf("dm.stmts=%v", stmts)
+	}
+}
@@ -25,6 +25,8 @@ var gormOnce sync.Once
 type Gorm struct {
 	DB     *gorm.DB
 	Option *Option
+	retry  *RetryPolicy
+	batch  *BatchOptions
 }
 
 type Option struct {
@@ -34,6 +36,8 @@ type Option struct {
 	DbType         int
 	MaxConnections int
 	LogLevel       logger.LogLevel
+	// MultiStatements MySQL连接开启 multiStatements、interpolateParams，WithBatch 时多条语句合并发送
+	MultiStatements bool
 }
 
 func GetConn() *Gorm {
@@ -70,51 +74,111 @@ func (opt *Option) GetTableName(table string) string {
 }
 
 func (opt *Option) GetTable(model interface{}) string {
+	return opt.GetTableName(tableName(model))
+}
+
+// tableName 模型对应的表名，未加引号及模式
+func tableName(model interface{}) string {
 	switch model.(type) {
 	case string:
-		return opt.GetTableName(model.(string))
+		return model.(string)
 	}
 	fv := reflect.ValueOf(model)
 	method := fv.MethodByName("TableName")
-	tableName := fmt.Sprint(method.Call(nil)[0])
-	return opt.GetTableName(tableName)
+	return fmt.Sprint(method.Call(nil)[0])
 }
 
 func (opt *Option) GetInsertSQL(model interface{}, data map[string]interface{}) *TranSQL {
 	if model == nil || len(data) == 0 {
 		return nil
 	}
+	tags, params := insertValues(model, data)
+	if len(params) == 0 {
+		return nil
+	}
+	columns := lo.Map(tags, func(tag sys.Prop, _ int) string {
+		return tag.GormP.Column
+	})
+	sql := fmt.Sprintf("INSERT INTO %s(%s) VALUES%s", opt.GetTable(model), strings.Join(columns, ","), toSqlIn(len(params)))
+	return &TranSQL{SQL: sql, Params: params}
+}
+
+// insertValues INSERT的字段及参数，data中没有的字段使用sorm默认值
+func insertValues(model interface{}, data map[string]interface{}) ([]sys.Prop, []interface{}) {
 	keys := make(map[string]string)
 	for k := range data {
 		keys[strings.ToLower(k)] = k
 	}
-	var build strings.Builder
-	build.WriteString(fmt.Sprintf("INSERT INTO %s(", opt.GetTable(model)))
+	var tags []sys.Prop
 	var params []interface{}
-	first := true
 	for _, tag := range sys.GetTags(model) {
-		if v, ok := keys[strings.ToLower(tag.Name)]; ok {
-			if g := tag.GormP; g != nil && g.Column != "" {
+		if g := tag.GormP; g != nil && g.Column != "" {
+			if v, ok := keys[strings.ToLower(tag.Name)]; ok {
+				tags = append(tags, tag)
 				params = append(params, toData(tag, data[v]))
-				build.WriteString(lo.Ternary(first, g.Column, ","+g.Column))
-				first = false
+			} else if s := tag.SormP; s != nil && s.Default != "" {
+				tags = append(tags, tag)
+				params = append(params, s.Default)
 			}
-		} else {
-			if g := tag.GormP; g != nil && g.Column != "" {
-				if s := tag.SormP; s != nil && s.Default != "" {
-					params = append(params, s.Default)
-					build.WriteString(lo.Ternary(first, g.Column, ","+g.Column))
-					first = false
-				}
+		}
+	}
+	return tags, params
+}
+
+// GetBatchInsertSQL 多行INSERT，字段为各行字段的并集，某行缺少的字段使用sorm默认值，没有默认值时为DEFAULT；
+// 按 driver.GetMaxParams 拆分为多条
+func (opt *Option) GetBatchInsertSQL(model interface{}, data []map[string]interface{}) []TranSQL {
+	if model == nil || len(data) == 0 {
+		return nil
+	}
+	rows := make([]map[string]string, len(data))
+	union := make(map[string]bool)
+	for i, v := range data {
+		rows[i] = make(map[string]string)
+		for k := range v {
+			rows[i][strings.ToLower(k)] = k
+			union[strings.ToLower(k)] = true
+		}
+	}
+	var tags []sys.Prop
+	var columns []string
+	for _, tag := range sys.GetTags(model) {
+		if g := tag.GormP; g != nil && g.Column != "" {
+			if union[strings.ToLower(tag.Name)] || (tag.SormP != nil && tag.SormP.Default != "") {
+				tags = append(tags, tag)
+				columns = append(columns, g.Column)
 			}
 		}
 	}
-	build.WriteString(") VALUES")
-	if len(params) == 0 {
+	if len(columns) == 0 {
 		return nil
 	}
-	build.WriteString(toSqlIn(len(params)))
-	return &TranSQL{SQL: build.String(), Params: params}
+	size := lo.Max([]int{driver.GetMaxParams(opt.DbType) / len(columns), 1})
+	head := fmt.Sprintf("INSERT INTO %s(%s) VALUES", opt.GetTable(model), strings.Join(columns, ","))
+	var resp []TranSQL
+	for _, chunk := range lo.Chunk(lo.Range(len(data)), size) {
+		var build strings.Builder
+		var params []interface{}
+		build.WriteString(head)
+		for k, i := range chunk {
+			build.WriteString(lo.Ternary(k == 0, "(", ",("))
+			for n, tag := range tags {
+				build.WriteString(lo.Ternary(n == 0, "", ","))
+				if v, ok := rows[i][strings.ToLower(tag.Name)]; ok {
+					params = append(params, toData(tag, data[i][v]))
+					build.WriteString("?")
+				} else if s := tag.SormP; s != nil && s.Default != "" {
+					params = append(params, s.Default)
+					build.WriteString("?")
+				} else {
+					build.WriteString("DEFAULT")
+				}
+			}
+			build.WriteString(")")
+		}
+		resp = append(resp, TranSQL{SQL: build.String(), Params: params})
+	}
+	return resp
 }
 
 func (opt *Option) GetUpdateSQL(model interface{}, pks map[string]interface{}, data map[string]interface{}) *TranSQL {
@@ -150,6 +214,137 @@ func (opt *Option) GetUpdateSQL(model interface{}, pks map[string]interface{}, d
 	return &TranSQL{SQL: build.String(), Params: params}
 }
 
+// GetUpsertSQL 插入，冲突时更新：MySQL为 ON DUPLICATE KEY UPDATE，优炫、海量为 ON CONFLICT，达梦为 MERGE INTO。
+// conflictColumns为空时使用主键、uniqueIndex中字段都在data内的第一组；updateColumns为nil时更新主键外的插入字段，
+// 为空切片时冲突不更新，冲突字段总是从更新字段中排除；字段可使用属性名或数据库字段名，且必须在插入字段内，否则返回nil
+func (opt *Option) GetUpsertSQL(model interface{}, data map[string]interface{}, conflictColumns []string, updateColumns []string) *TranSQL {
+	if model == nil || len(data) == 0 {
+		return nil
+	}
+	tags, params := insertValues(model, data)
+	if len(params) == 0 {
+		return nil
+	}
+	conflict, ok := upsertColumns(tags, conflictColumns)
+	if !ok {
+		return nil
+	}
+	if len(conflictColumns) == 0 {
+		conflict = conflictKeys(model, tags)
+	}
+	if len(conflict) == 0 && lo.Contains([]int{driver.DBTypeUxDB, driver.DBTypeDmDB, driver.DBTypeVbDB}, opt.DbType) {
+		return nil
+	}
+	update, ok := upsertColumns(tags, updateColumns)
+	if !ok {
+		return nil
+	}
+	if updateColumns == nil {
+		for _, tag := range tags {
+			if !tag.GormP.Pk {
+				update = append(update, tag.GormP.Column)
+			}
+		}
+	}
+	// 冲突字段不更新，达梦MERGE不允许更新ON中的字段
+	update = lo.Filter(update, func(v string, _ int) bool {
+		return !lo.Contains(conflict, v)
+	})
+	columns := lo.Map(tags, func(tag sys.Prop, _ int) string {
+		return tag.GormP.Column
+	})
+	exp := driver.Exp{DbType: opt.DbType, Schema: opt.Schema}
+	table := opt.GetTable(model)
+	var build strings.Builder
+	switch opt.DbType {
+	case driver.DBTypeDmDB:
+		build.WriteString(fmt.Sprintf("MERGE INTO %s t USING (SELECT ", table))
+		for k, v := range columns {
+			build.WriteString(fmt.Sprintf("%s? AS %s", lo.Ternary(k == 0, "", ","), exp.Quote(v)))
+		}
+		build.WriteString(" FROM DUAL) s ON (")
+		for k, v := range conflict {
+			build.WriteString(fmt.Sprintf("%st.%s=s.%s", lo.Ternary(k == 0, "", " AND "), exp.Quote(v), exp.Quote(v)))
+		}
+		build.WriteString(")")
+		for k, v := range update {
+			build.WriteString(fmt.Sprintf("%st.%s=s.%s", lo.Ternary(k == 0, " WHEN MATCHED THEN UPDATE SET ", ","), exp.Quote(v), exp.Quote(v)))
+		}
+		quoted := lo.Map(columns, func(v string, _ int) string {
+			return exp.Quote(v)
+		})
+		build.WriteString(fmt.Sprintf(" WHEN NOT MATCHED THEN INSERT (%s) VALUES (s.%s)", strings.Join(quoted, ","), strings.Join(quoted, ",s.")))
+	case driver.DBTypeUxDB, driver.DBTypeVbDB:
+		build.WriteString(fmt.Sprintf("INSERT INTO %s(%s) VALUES%s ON CONFLICT (", table, strings.Join(columns, ","), toSqlIn(len(params))))
+		for k, v := range conflict {
+			build.WriteString(lo.Ternary(k == 0, "", ",") + exp.Quote(v))
+		}
+		build.WriteString(lo.Ternary(len(update) == 0, ") DO NOTHING", ") DO UPDATE SET "))
+		for k, v := range update {
+			build.WriteString(fmt.Sprintf("%s%s=EXCLUDED.%s", lo.Ternary(k == 0, "", ","), exp.Quote(v), exp.Quote(v)))
+		}
+	default:
+		build.WriteString(fmt.Sprintf("INSERT INTO %s(%s) VALUES%s ON DUPLICATE KEY UPDATE ", table, strings.Join(columns, ","), toSqlIn(len(params))))
+		if len(update) == 0 {
+			// 没有更新字段时赋值为自身，冲突时不修改记录
+			v := exp.Quote(lo.Ternary(len(conflict) > 0, conflict, columns)[0])
+			build.WriteString(v + "=" + v)
+		}
+		for k, v := range update {
+			build.WriteString(fmt.Sprintf("%s%s=VALUES(%s)", lo.Ternary(k == 0, "", ","), exp.Quote(v), exp.Quote(v)))
+		}
+	}
+	return &TranSQL{SQL: build.String(), Params: params}
+}
+
+// upsertColumns 将属性名或字段名转换为插入字段中的数据库字段，不在插入字段内时返回false
+func upsertColumns(tags []sys.Prop, names []string) ([]string, bool) {
+	var resp []string
+	for _, name := range names {
+		tag, ok := lo.Find(tags, func(tag sys.Prop) bool {
+			return strings.EqualFold(tag.Name, name) || strings.EqualFold(tag.GormP.Column, name)
+		})
+		if !ok {
+			return nil, false
+		}
+		resp = append(resp, tag.GormP.Column)
+	}
+	return resp, true
+}
+
+// conflictKeys 默认冲突字段，依次为主键、各唯一索引中字段都在插入字段内的第一组
+func conflictKeys(model interface{}, tags []sys.Prop) []string {
+	var pks []string
+	var names []string
+	uniques := make(map[string][]string)
+	for _, tag := range sys.GetTags(model) {
+		if g := tag.GormP; g != nil && g.Column != "" {
+			if g.Pk {
+				pks = append(pks, g.Column)
+			}
+			if g.Unique != "" {
+				if _, ok := uniques[g.Unique]; !ok {
+					names = append(names, g.Unique)
+				}
+				uniques[g.Unique] = append(uniques[g.Unique], g.Column)
+			}
+		}
+	}
+	inserted := lo.Map(tags, func(tag sys.Prop, _ int) string {
+		return tag.GormP.Column
+	})
+	groups := [][]string{pks}
+	for _, v := range names {
+		groups = append(groups, uniques[v])
+	}
+	for _, v := range groups {
+		if len(v) > 0 && lo.Every(inserted, v) {
+			return v
+		}
+	}
+	return nil
+}
+
 func toData(prop sys.Prop, value interface{}) interface{} {
 	var format string
 	if v := prop.SormP; v != nil {
@@ -235,6 +430,9 @@ func (opt *Option) initVb() (*Gorm, error) {
 func (opt *Option) initMySQL() (*Gorm, error) {
 	dsn := toMyDsn(opt.DataSourceName)
 	dsn = fmt.Sprintf("%s%s?%s", dsn, opt.DbName, "charset=utf8mb4&parseTime=true&loc=Asia%2fShanghai")
+	if opt.MultiStatements {
+		dsn += "&multiStatements=true&interpolateParams=true"
+	}
 	gorm, err := initDB(mysql.New(mysql.Config{DSN: dsn}), opt.MaxConnections, opt.LogLevel)
 	return &Gorm{DB: gorm, Option: opt}, err
 }
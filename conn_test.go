@@ -1,7 +1,9 @@
 package gorm
 
 import (
+	"gitops.sudytech.cn/guolei/gorm/driver"
 	"gitops.sudytech.cn/guolei/gorm/testdata"
+	"strings"
 	"testing"
 )
 
@@ -20,3 +22,58 @@ func TestOption_GetInsertSQL(t *testing.T) {
 		}
 	}
 }
+
+func TestOption_GetBatchInsertSQL(t *testing.T) {
+	opt := &Option{DbType: driver.DBTypeUxDB}
+	data := []map[string]interface{}{{"name": "a", "type": 1}, {"NAME": "b", "code": "c"}}
+	sqls := opt.GetBatchInsertSQL(&testdata.Algorithm{}, data)
+	expect := `INSERT INTO "T_TEST_ALGORITHM"(code,name,type) VALUES(?,?,?),(?,?,DEFAULT)`
+	if len(sqls) != 1 || sqls[0].SQL != expect {
+		t.Fatalf("sqls=%v,expect=%s", sqls, expect)
+	}
+	if params := sqls[0].Params; len(params) != 5 || params[0] != "测试" || params[3] != "c" || params[4] != "b" {
+		t.Fatalf("params=%v", params)
+	}
+	for i := 0; i < 30000; i++ {
+		data = append(data, map[string]interface{}{"name": "n", "type": i, "code": "c"})
+	}
+	sqls = opt.GetBatchInsertSQL(&testdata.Algorithm{}, data)
+	if len(sqls) != 2 || len(sqls[0].Params) > 65535 || len(sqls[0].Params)+len(sqls[1].Params) != 5+3*30000 {
+		t.Fatalf("chunks=%d", len(sqls))
+	}
+}
+
+func TestOption_GetUpsertSQL(t *testing.T) {
+	data := map[string]interface{}{"code": "a", "name": "n", "type": 2}
+	testdatas := []struct {
+		dbType int
+		update []string
+		expect string
+	}{
+		{driver.DBTypeMySQL, nil, "INSERT INTO T_TEST_ALGORITHM(code,name,type) VALUES(?,?,?) ON DUPLICATE KEY UPDATE `name`=VALUES(`name`),`type`=VALUES(`type`)"},
+		{driver.DBTypeMySQL, []string{}, "INSERT INTO T_TEST_ALGORITHM(code,name,type) VALUES(?,?,?) ON DUPLICATE KEY UPDATE `code`=`code`"},
+		{driver.DBTypeUxDB, []string{"Name"}, `INSERT INTO "T_TEST_ALGORITHM"(code,name,type) VALUES(?,?,?) ON CONFLICT ("code") DO UPDATE SET "name"=EXCLUDED."name"`},
+		{driver.DBTypeVbDB, []string{}, `INSERT INTO "T_TEST_ALGORITHM"(code,name,type) VALUES(?,?,?) ON CONFLICT ("code") DO NOTHING`},
+		{driver.DBTypeVbDB, []string{"code"}, `INSERT INTO "T_TEST_ALGORITHM"(code,name,type) VALUES(?,?,?) ON CONFLICT ("code") DO NOTHING`},
+		{driver.DBTypeMySQL, []string{"Code", "name"}, "INSERT INTO T_TEST_ALGORITHM(code,name,type) VALUES(?,?,?) ON DUPLICATE KEY UPDATE `name`=VALUES(`name`)"},
+		{driver.DBTypeDmDB, []string{"code", "type"}, `MERGE INTO "T_TEST_ALGORITHM" t USING (SELECT ? AS "code",? AS "name",? AS "type" FROM DUAL) s ON (t."code"=s."code")` +
+			` WHEN MATCHED THEN UPDATE SET t."type"=s."type" WHEN NOT MATCHED THEN INSERT ("code","name","type") VALUES (s."code",s."name",s."type")`},
+		{driver.DBTypeDmDB, nil, `MERGE INTO "T_TEST_ALGORITHM" t USING (SELECT ? AS "code",? AS "name",? AS "type" FROM DUAL) s ON (t."code"=s."code")` +
+			` WHEN MATCHED THEN UPDATE SET t."name"=s."name",t."type"=s."type" WHEN NOT MATCHED THEN INSERT ("code","name","type") VALUES (s."code",s."name",s."type")`},
+	}
+	for _, v := range testdatas {
+		opt := &Option{DbType: v.dbType}
+		sql := opt.GetUpsertSQL(&testdata.Algorithm{}, data, nil, v.update)
+		if sql == nil || sql.SQL != v.expect || len(sql.Params) != 3 || sql.Params[0] != "a" {
+			t.Fatalf("dbType=%d,sql=%v,expect=%s", v.dbType, sql, v.expect)
+		}
+	}
+	data["id"] = 1
+	opt := &Option{DbType: driver.DBTypeUxDB}
+	if sql := opt.GetUpsertSQL(&testdata.Algorithm{}, data, nil, nil); sql == nil || !strings.Contains(sql.SQL, `ON CONFLICT ("id") DO UPDATE SET "code"=EXCLUDED."code"`) {
+		t.Fatalf("pk conflict:sql=%v", sql)
+	}
+	if sql := opt.GetUpsertSQL(&testdata.Algorithm{}, data, []string{"impl"}, nil); sql != nil {
+		t.Fatalf("conflict column not inserted must return nil")
+	}
+}
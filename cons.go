@@ -1,10 +1,15 @@
 package gorm
 
 import (
+	"errors"
 	"fmt"
 	"github.com/samber/lo"
 	"github.com/spf13/cast"
+	"gitops.sudytech.cn/guolei/gorm/driver"
+	"gitops.sudytech.cn/guolei/gorm/sys"
 	"google.golang.org/protobuf/types/known/timestamppb"
+	"reflect"
+	"sort"
 	"strings"
 	"time"
 )
@@ -21,6 +26,8 @@ type ConsWrapper interface {
 	AsOrConstraint() *OrConstraint
 	IsAnd() bool
 	AsAndConstraint() *AndConstraint
+	IsNot() bool
+	AsNotConstraint() *NotConstraint
 }
 
 type Constraint struct {
@@ -53,6 +60,20 @@ func (Constraint) AsAndConstraint() *AndConstraint {
 	return nil
 }
 
+func (Constraint) IsNot() bool {
+	return false
+}
+
+func (Constraint) AsNotConstraint() *NotConstraint {
+	return nil
+}
+
+// SubQuery 子查询，可作为 CompareIn/CompareNotIn 的值
+type SubQuery struct {
+	SQL    string
+	Params []interface{}
+}
+
 type OrConstraint struct {
 	Wrapper []ConsWrapper
 }
@@ -81,6 +102,14 @@ func (OrConstraint) AsAndConstraint() *AndConstraint {
 	return nil
 }
 
+func (OrConstraint) IsNot() bool {
+	return false
+}
+
+func (OrConstraint) AsNotConstraint() *NotConstraint {
+	return nil
+}
+
 type AndConstraint struct {
 	Wrapper []ConsWrapper
 }
@@ -109,6 +138,51 @@ func (cons AndConstraint) AsAndConstraint() *AndConstraint {
 	return &cons
 }
 
+func (AndConstraint) IsNot() bool {
+	return false
+}
+
+func (AndConstraint) AsNotConstraint() *NotConstraint {
+	return nil
+}
+
+// NotConstraint 对条件取反，生成 NOT (...)
+type NotConstraint struct {
+	Wrapper ConsWrapper
+}
+
+func (NotConstraint) IsCons() bool {
+	return false
+}
+
+func (NotConstraint) AsConstraint() *Constraint {
+	return nil
+}
+
+func (NotConstraint) IsOr() bool {
+	return false
+}
+
+func (NotConstraint) AsOrConstraint() *OrConstraint {
+	return nil
+}
+
+func (NotConstraint) IsAnd() bool {
+	return false
+}
+
+func (NotConstraint) AsAndConstraint() *AndConstraint {
+	return nil
+}
+
+func (NotConstraint) IsNot() bool {
+	return true
+}
+
+func (cons NotConstraint) AsNotConstraint() *NotConstraint {
+	return &cons
+}
+
 const (
 	CompareEqual              = 0  //=
 	CompareLessThan           = 1  //<
@@ -122,6 +196,14 @@ const (
 	CompareNotLike            = 9  //Not Like
 	CompareIsNull             = 10 //Is Null
 	CompareNotNull            = 11 //Is Not Null
+	CompareBetween            = 12 //Between，值为两个元素的切片，nil表示该端不限
+	CompareNotBetween         = 13 //Not Between
+	CompareStartsWith         = 14 //Like 'v%'
+	CompareEndsWith           = 15 //Like '%v'
+	CompareILike              = 16 //忽略大小写的Like
+	CompareExists             = 17 //Exists，值为SubQuery
+	CompareNotExists          = 18 //Not Exists，值为SubQuery
+	CompareRaw                = 19 //原生表达式，Name为表达式，Value为参数
 )
 
 func GetSymbol(compare int) string {
@@ -150,20 +232,88 @@ func GetSymbol(compare int) string {
 		return "IS NULL"
 	case CompareNotNull:
 		return "IS NOT NULL"
+	case CompareBetween:
+		return "BETWEEN"
+	case CompareNotBetween:
+		return "NOT BETWEEN"
+	case CompareStartsWith, CompareEndsWith:
+		return "LIKE"
+	case CompareILike:
+		return "ILIKE"
+	case CompareExists:
+		return "EXISTS"
+	case CompareNotExists:
+		return "NOT EXISTS"
+	case CompareRaw:
+		return ""
 	default:
 		return "="
 	}
 }
 
+// Like匹配方式
+const (
+	LikeContains   = 0 //包含，%v%
+	LikeStartsWith = 1 //前缀，v%
+	LikeEndsWith   = 2 //后缀，%v
+)
+
 func GenCons(name string, value interface{}, compare int) Constraint {
-	if compare != CompareLike {
+	switch compare {
+	case CompareLike, CompareNotLike, CompareILike:
+		return GenLikeCons(name, value, compare, LikeContains)
+	case CompareStartsWith:
+		return GenLikeCons(name, value, compare, LikeStartsWith)
+	case CompareEndsWith:
+		return GenLikeCons(name, value, compare, LikeEndsWith)
+	default:
 		return Constraint{Name: name, Value: value, Compare: compare}
 	}
-	return Constraint{Name: name, Value: "%" + fmt.Sprint(value) + "%", Compare: compare}
+}
+
+// GenLikeCons Like条件，转义值中的%、_、\后按mode添加通配符，生成SQL时附加ESCAPE子句
+func GenLikeCons(name string, value interface{}, compare int, mode int) Constraint {
+	v := EscapeLike(fmt.Sprint(value))
+	switch mode {
+	case LikeStartsWith:
+		v = v + "%"
+	case LikeEndsWith:
+		v = "%" + v
+	default:
+		v = "%" + v + "%"
+	}
+	return Constraint{Name: name, Value: v, Compare: compare}
+}
+
+var likeEscaper = strings.NewReplacer("\\", "\\\\", "%", "\\%", "_", "\\_")
+
+// EscapeLike 转义Like通配符，转义字符为\
+func EscapeLike(value string) string {
+	return likeEscaper.Replace(value)
+}
+
+func GenBetween(name string, begin interface{}, end interface{}) Constraint {
+	return Constraint{Name: name, Value: []interface{}{begin, end}, Compare: CompareBetween}
+}
+
+func GenExists(sub SubQuery) Constraint {
+	return Constraint{Value: sub, Compare: CompareExists}
+}
+
+func GenNotExists(sub SubQuery) Constraint {
+	return Constraint{Value: sub, Compare: CompareNotExists}
+}
+
+// GenRaw 原生表达式条件，表达式中的?与params一一对应，表达式不得拼接外部输入
+func GenRaw(sql string, params ...interface{}) Constraint {
+	return Constraint{Name: sql, Value: params, Compare: CompareRaw}
+}
+
+func GenNot(wp ConsWrapper) NotConstraint {
+	return NotConstraint{Wrapper: wp}
 }
 
 func GenConsWrapper(constraint map[string]interface{}, compareMatch map[string]int, orMatch map[string]interface{}) []ConsWrapper {
-	var or []ConsWrapper
 	var wrapper []ConsWrapper
 	if len(constraint) == 0 {
 		return wrapper
@@ -174,7 +324,55 @@ func GenConsWrapper(constraint map[string]interface{}, compareMatch map[string]i
 	if compareMatch == nil {
 		compareMatch = make(map[string]int, 0)
 	}
-	for k, v := range constraint {
+	keys := lo.Keys(constraint)
+	sort.Strings(keys)
+	return genConsWrapper(keys, constraint, compareMatch, orMatch)
+}
+
+// GenModelConsWrapper 同 GenConsWrapper，条件按模型字段定义顺序排列，模型外的字段按名称排序追加在后
+func GenModelConsWrapper(model interface{}, constraint map[string]interface{}, compareMatch map[string]int, orMatch map[string]interface{}) []ConsWrapper {
+	if len(constraint) == 0 {
+		return nil
+	}
+	if orMatch == nil {
+		orMatch = make(map[string]interface{}, 0)
+	}
+	if compareMatch == nil {
+		compareMatch = make(map[string]int, 0)
+	}
+	index := make(map[string]int)
+	for k, tag := range sys.GetTags(model) {
+		names := []string{tag.Name, tag.Json}
+		if g := tag.GormP; g != nil {
+			names = append(names, g.Column)
+		}
+		for _, v := range names {
+			if _, ok := index[strings.ToLower(v)]; v != "" && !ok {
+				index[strings.ToLower(v)] = k
+			}
+		}
+	}
+	keys := lo.Keys(constraint)
+	sort.SliceStable(keys, func(i, j int) bool {
+		ki, oki := index[strings.ToLower(keys[i])]
+		kj, okj := index[strings.ToLower(keys[j])]
+		switch {
+		case oki && okj && ki != kj:
+			return ki < kj
+		case oki != okj:
+			return oki
+		default:
+			return keys[i] < keys[j]
+		}
+	})
+	return genConsWrapper(keys, constraint, compareMatch, orMatch)
+}
+
+func genConsWrapper(keys []string, constraint map[string]interface{}, compareMatch map[string]int, orMatch map[string]interface{}) []ConsWrapper {
+	var or []ConsWrapper
+	var wrapper []ConsWrapper
+	for _, k := range keys {
+		v := constraint[k]
 		if _, ok0 := orMatch[k]; ok0 {
 			if compare, ok := compareMatch[k]; ok {
 				or = append(or, GenCons(k, v, compare))
@@ -195,6 +393,79 @@ func GenConsWrapper(constraint map[string]interface{}, compareMatch map[string]i
 	return append(wrapper, OrConstraint{Wrapper: or})
 }
 
+// ConsBuilder 按添加顺序生成查询条件，用于替代map形式的 GenConsWrapper，生成的SQL顺序固定
+type ConsBuilder struct {
+	wrapper []ConsWrapper
+	or      []ConsWrapper
+}
+
+func NewConsBuilder() *ConsBuilder {
+	return &ConsBuilder{}
+}
+
+// Add 添加AND条件，compare为 CompareLike 等时同 GenCons 处理值
+func (b *ConsBuilder) Add(name string, value interface{}, compare int) *ConsBuilder {
+	b.wrapper = append(b.wrapper, GenCons(name, value, compare))
+	return b
+}
+
+// AddOr 添加到OR条件组，所有OR条件最终作为一个整体与其它条件AND
+func (b *ConsBuilder) AddOr(name string, value interface{}, compare int) *ConsBuilder {
+	b.or = append(b.or, GenCons(name, value, compare))
+	return b
+}
+
+// AddWrapper 直接添加条件
+func (b *ConsBuilder) AddWrapper(wrapper ...ConsWrapper) *ConsBuilder {
+	b.wrapper = append(b.wrapper, wrapper...)
+	return b
+}
+
+// Str 同 StrCondition，空字符串不生成条件
+func (b *ConsBuilder) Str(name string, value string, compare int) *ConsBuilder {
+	return b.put(name, compare, func(constraint map[string]interface{}) {
+		StrCondition(constraint, name, value)
+	})
+}
+
+// Int 同 IntCondition，0不生成条件，-1转换为0
+func (b *ConsBuilder) Int(name string, value interface{}, compare int) *ConsBuilder {
+	return b.put(name, compare, func(constraint map[string]interface{}) {
+		IntCondition(constraint, name, value)
+	})
+}
+
+// Threshold 同 IntThreshold，大于阈值才生成条件
+func (b *ConsBuilder) Threshold(name string, value interface{}, threshold int, compare int) *ConsBuilder {
+	return b.put(name, compare, func(constraint map[string]interface{}) {
+		IntThreshold(constraint, name, value, threshold)
+	})
+}
+
+// Time 同 TimeConstraint，nil不生成条件
+func (b *ConsBuilder) Time(name string, value *timestamppb.Timestamp, compare int) *ConsBuilder {
+	b.wrapper = TimeConstraint(b.wrapper, name, value, compare)
+	return b
+}
+
+func (b *ConsBuilder) put(name string, compare int, fn func(constraint map[string]interface{})) *ConsBuilder {
+	constraint := make(map[string]interface{})
+	fn(constraint)
+	if v, ok := constraint[name]; ok {
+		b.Add(name, v, compare)
+	}
+	return b
+}
+
+func (b *ConsBuilder) Build() []ConsWrapper {
+	var wrapper []ConsWrapper
+	wrapper = append(wrapper, b.wrapper...)
+	if len(b.or) == 0 {
+		return wrapper
+	}
+	return append(wrapper, OrConstraint{Wrapper: append([]ConsWrapper{}, b.or...)})
+}
+
 func GenOrderSQL(build *strings.Builder, orders []QueryOrder) {
 	if len(orders) > 0 {
 		build.WriteString(" ORDER BY ")
@@ -206,6 +477,66 @@ func GenOrderSQL(build *strings.Builder, orders []QueryOrder) {
 }
 
 func GenWhereSQL(build *strings.Builder, cons []ConsWrapper) []interface{} {
+	return GenDbWhereSQL(build, driver.DBTypeMySQL, cons)
+}
+
+// CheckCons 校验条件：EXISTS、NOT EXISTS及IN子查询须为非空的 SubQuery，NOT条件不能为空
+func CheckCons(cons []ConsWrapper) error {
+	for _, v := range cons {
+		if err := checkCons(v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func checkCons(wp ConsWrapper) error {
+	if wp == nil {
+		return errors.New("条件为空")
+	}
+	if wp.IsCons() {
+		cons := wp.AsConstraint()
+		switch cons.Compare {
+		case CompareExists, CompareNotExists:
+			if _, ok := toSubQuery(cons.Value); !ok {
+				return fmt.Errorf("%s子查询为空或类型错误", GetSymbol(cons.Compare))
+			}
+		case CompareIn, CompareNotIn:
+			switch cons.Value.(type) {
+			case SubQuery, *SubQuery:
+				if _, ok := toSubQuery(cons.Value); !ok {
+					return fmt.Errorf("%s: %s子查询为空", cons.Name, GetSymbol(cons.Compare))
+				}
+			}
+		}
+	} else if wp.IsOr() {
+		if or := wp.AsOrConstraint(); or != nil {
+			return CheckCons(or.Wrapper)
+		}
+	} else if wp.IsAnd() {
+		if and := wp.AsAndConstraint(); and != nil {
+			return CheckCons(and.Wrapper)
+		}
+	} else if wp.IsNot() {
+		if not := wp.AsNotConstraint(); not == nil || not.Wrapper == nil {
+			return errors.New("NOT条件为空")
+		}
+		return checkCons(wp.AsNotConstraint().Wrapper)
+	}
+	return nil
+}
+
+// genWhereSQL 校验条件后生成查询条件
+func genWhereSQL(build *strings.Builder, dbType int, cons []ConsWrapper) ([]interface{}, error) {
+	if err := CheckCons(cons); err != nil {
+		return nil, err
+	}
+	return GenDbWhereSQL(build, dbType, cons), nil
+}
+
+// GenDbWhereSQL 按数据库类型生成查询条件，IN列表按数据库限制拆分；
+// 不校验条件，无效的子查询按空集、空的NOT条件按恒为假生成，需要报错时先调用 CheckCons
+func GenDbWhereSQL(build *strings.Builder, dbType int, cons []ConsWrapper) []interface{} {
 	var params []interface{}
 	if len(cons) == 0 {
 		return params
@@ -222,7 +553,7 @@ func GenWhereSQL(build *strings.Builder, cons []ConsWrapper) []interface{} {
 				build.WriteString(" AND (")
 			}
 		}
-		param := genConsSQL(build, v)
+		param := genConsSQL(build, dbType, v)
 		params = append(params, param...)
 	}
 	if flag {
@@ -231,19 +562,51 @@ func GenWhereSQL(build *strings.Builder, cons []ConsWrapper) []interface{} {
 	return params
 }
 
-func genConsSQL(build *strings.Builder, wp ConsWrapper) []interface{} {
+func genConsSQL(build *strings.Builder, dbType int, wp ConsWrapper) []interface{} {
 	var params []interface{}
 	if wp.IsCons() {
 		cons := wp.AsConstraint()
-		params = append(params, cons.Value)
-		build.WriteString(fmt.Sprintf("%s %s ?", cons.Name, GetSymbol(cons.Compare)))
+		switch cons.Compare {
+		case CompareIn, CompareNotIn:
+			params = append(params, genInSQL(build, dbType, cons)...)
+		case CompareIsNull, CompareNotNull:
+			build.WriteString(fmt.Sprintf("%s %s", cons.Name, GetSymbol(cons.Compare)))
+		case CompareBetween, CompareNotBetween:
+			params = append(params, genBetweenSQL(build, cons)...)
+		case CompareLike, CompareNotLike, CompareStartsWith, CompareEndsWith:
+			params = append(params, cons.Value)
+			build.WriteString(fmt.Sprintf("%s %s ?%s", cons.Name, GetSymbol(cons.Compare), driver.GetLikeEscape(dbType)))
+		case CompareILike:
+			params = append(params, cons.Value)
+			switch dbType {
+			case driver.DBTypeUxDB, driver.DBTypeVbDB:
+				build.WriteString(fmt.Sprintf("%s ILIKE ?%s", cons.Name, driver.GetLikeEscape(dbType)))
+			default:
+				build.WriteString(fmt.Sprintf("UPPER(%s) LIKE UPPER(?)%s", cons.Name, driver.GetLikeEscape(dbType)))
+			}
+		case CompareExists, CompareNotExists:
+			sub, ok := toSubQuery(cons.Value)
+			if !ok {
+				// 子查询无效时按空集处理：EXISTS恒为假，NOT EXISTS恒为真
+				build.WriteString(lo.Ternary(cons.Compare == CompareExists, "1=0", "1=1"))
+				break
+			}
+			params = append(params, sub.Params...)
+			build.WriteString(fmt.Sprintf("%s (%s)", GetSymbol(cons.Compare), sub.SQL))
+		case CompareRaw:
+			params = append(params, toValues(cons.Value)...)
+			build.WriteString(fmt.Sprintf("(%s)", cons.Name))
+		default:
+			params = append(params, cons.Value)
+			build.WriteString(fmt.Sprintf("%s %s ?", cons.Name, GetSymbol(cons.Compare)))
+		}
 	} else if wp.IsOr() {
 		or := wp.AsOrConstraint()
 		build.WriteString("(")
 		if or != nil {
 			for k, v := range or.Wrapper {
 				build.WriteString(lo.Ternary(k == 0, "", " OR "))
-				orParam := genConsSQL(build, v)
+				orParam := genConsSQL(build, dbType, v)
 				params = append(params, orParam...)
 			}
 		}
@@ -254,15 +617,121 @@ func genConsSQL(build *strings.Builder, wp ConsWrapper) []interface{} {
 		if and != nil {
 			for k, v := range and.Wrapper {
 				build.WriteString(lo.Ternary(k == 0, "", " AND "))
-				andParam := genConsSQL(build, v)
+				andParam := genConsSQL(build, dbType, v)
 				params = append(params, andParam...)
 			}
 		}
 		build.WriteString(")")
+	} else if wp.IsNot() {
+		not := wp.AsNotConstraint()
+		if not == nil || not.Wrapper == nil {
+			// 空的NOT条件恒为假，避免误放开查询范围
+			build.WriteString("1=0")
+			return params
+		}
+		build.WriteString("NOT (")
+		params = append(params, genConsSQL(build, dbType, not.Wrapper)...)
+		build.WriteString(")")
 	}
 	return params
 }
 
+// genBetweenSQL 区间条件，任意一端为nil时退化为单边比较，两端均为nil时恒为真
+func genBetweenSQL(build *strings.Builder, cons *Constraint) []interface{} {
+	values := toValues(cons.Value)
+	var begin, end interface{}
+	if len(values) > 0 {
+		begin = values[0]
+	}
+	if len(values) > 1 {
+		end = values[1]
+	}
+	between := cons.Compare == CompareBetween
+	switch {
+	case begin != nil && end != nil:
+		build.WriteString(fmt.Sprintf("%s %s ? AND ?", cons.Name, GetSymbol(cons.Compare)))
+		return []interface{}{begin, end}
+	case begin != nil:
+		build.WriteString(fmt.Sprintf("%s %s ?", cons.Name, lo.Ternary(between, ">=", "<")))
+		return []interface{}{begin}
+	case end != nil:
+		build.WriteString(fmt.Sprintf("%s %s ?", cons.Name, lo.Ternary(between, "<=", ">")))
+		return []interface{}{end}
+	default:
+		build.WriteString(lo.Ternary(between, "1=1", "1=0"))
+		return nil
+	}
+}
+
+func genInSQL(build *strings.Builder, dbType int, cons *Constraint) []interface{} {
+	in := cons.Compare == CompareIn
+	switch cons.Value.(type) {
+	case SubQuery, *SubQuery:
+		sub, ok := toSubQuery(cons.Value)
+		if !ok {
+			build.WriteString(lo.Ternary(in, "1=0", "1=1"))
+			return nil
+		}
+		build.WriteString(fmt.Sprintf("%s %s (%s)", cons.Name, GetSymbol(cons.Compare), sub.SQL))
+		return sub.Params
+	}
+	values := toValues(cons.Value)
+	if len(values) == 0 {
+		// 空集合：IN恒为假，NOT IN恒为真
+		build.WriteString(lo.Ternary(in, "1=0", "1=1"))
+		return nil
+	}
+	chunks := [][]interface{}{values}
+	if size := driver.GetMaxInSize(dbType); size > 0 && len(values) > size {
+		chunks = lo.Chunk(values, size)
+	}
+	if len(chunks) > 1 {
+		build.WriteString("(")
+	}
+	for k, v := range chunks {
+		build.WriteString(lo.Ternary(k == 0, "", lo.Ternary(in, " OR ", " AND ")))
+		build.WriteString(fmt.Sprintf("%s %s %s", cons.Name, GetSymbol(cons.Compare), toSqlIn(len(v))))
+	}
+	if len(chunks) > 1 {
+		build.WriteString(")")
+	}
+	return values
+}
+
+// toSubQuery 转换为子查询，nil、类型错误或SQL为空时返回false
+func toSubQuery(value interface{}) (SubQuery, bool) {
+	switch v := value.(type) {
+	case *SubQuery:
+		if v != nil && v.SQL != "" {
+			return *v, true
+		}
+	case SubQuery:
+		return v, v.SQL != ""
+	}
+	return SubQuery{}, false
+}
+
+// toValues 将切片或数组展开为参数列表，[]byte及其它类型视为单个值
+func toValues(value interface{}) []interface{} {
+	if value == nil {
+		return nil
+	}
+	if _, ok := value.([]byte); ok {
+		return []interface{}{value}
+	}
+	rv := reflect.ValueOf(value)
+	switch rv.Kind() {
+	case reflect.Slice, reflect.Array:
+		values := make([]interface{}, 0, rv.Len())
+		for i := 0; i < rv.Len(); i++ {
+			values = append(values, rv.Index(i).Interface())
+		}
+		return values
+	default:
+		return []interface{}{value}
+	}
+}
+
 func StrCondition(constraint map[string]interface{}, name string, value string) {
 	if value != "" {
 		constraint[name] = value
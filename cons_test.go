@@ -0,0 +1,184 @@
+package gorm
+
+import (
+	"encoding/json"
+	"gitops.sudytech.cn/guolei/gorm/driver"
+	"gitops.sudytech.cn/guolei/gorm/testdata"
+	"strings"
+	"testing"
+)
+
+func TestGenDbWhereSQL_In(t *testing.T) {
+	testdatas := []struct {
+		cons   Constraint
+		expect string
+		params int
+	}{
+		{GenCons("id", []int64{1, 2, 3}, CompareIn), " AND (id IN (?,?,?))", 3},
+		{GenCons("id", [2]string{"a", "b"}, CompareNotIn), " AND (id NOT IN (?,?))", 2},
+		{GenCons("id", 1, CompareIn), " AND (id IN (?))", 1},
+		{GenCons("id", []int64{}, CompareIn), " AND (1=0)", 0},
+		{GenCons("id", nil, CompareNotIn), " AND (1=1)", 0},
+		{GenCons("id", SubQuery{SQL: "SELECT t.id FROM T_USER t WHERE t.type=?", Params: []interface{}{1}}, CompareIn), " AND (id IN (SELECT t.id FROM T_USER t WHERE t.type=?))", 1},
+	}
+	for _, item := range testdatas {
+		var build strings.Builder
+		params := GenWhereSQL(&build, []ConsWrapper{item.cons})
+		if build.String() != item.expect || len(params) != item.params {
+			t.Fatalf("sql=%s,params=%v,expect=%s", build.String(), params, item.expect)
+		}
+	}
+}
+
+func TestGenDbWhereSQL_InChunk(t *testing.T) {
+	size := driver.GetMaxInSize(driver.DBTypeDmDB)
+	values := make([]int, size+1)
+	var build strings.Builder
+	params := GenDbWhereSQL(&build, driver.DBTypeDmDB, []ConsWrapper{GenCons("id", values, CompareIn)})
+	if len(params) != size+1 || strings.Count(build.String(), "id IN (") != 2 || !strings.Contains(build.String(), "?) OR id IN (?)") {
+		t.Fatalf("sql=%s", build.String())
+	}
+	build.Reset()
+	GenDbWhereSQL(&build, driver.DBTypeDmDB, []ConsWrapper{GenCons("id", values, CompareNotIn)})
+	if !strings.Contains(build.String(), "?) AND id NOT IN (?)") {
+		t.Fatalf("sql=%s", build.String())
+	}
+	build.Reset()
+	GenDbWhereSQL(&build, driver.DBTypeMySQL, []ConsWrapper{GenCons("id", values, CompareIn)})
+	if strings.Count(build.String(), "id IN (") != 1 {
+		t.Fatalf("sql=%s", build.String())
+	}
+}
+
+func TestGenDbWhereSQL_Compare(t *testing.T) {
+	sub := SubQuery{SQL: "SELECT 1 FROM T_ORG o WHERE o.id=t.orgId AND o.type=?", Params: []interface{}{2}}
+	testdatas := []struct {
+		dbType int
+		cons   ConsWrapper
+		expect string
+		params int
+	}{
+		{driver.DBTypeMySQL, GenCons("name", nil, CompareIsNull), "name IS NULL", 0},
+		{driver.DBTypeMySQL, GenCons("name", nil, CompareNotNull), "name IS NOT NULL", 0},
+		{driver.DBTypeMySQL, GenBetween("sort", 1, 10), "sort BETWEEN ? AND ?", 2},
+		{driver.DBTypeMySQL, GenBetween("sort", 1, nil), "sort >= ?", 1},
+		{driver.DBTypeMySQL, GenCons("sort", []interface{}{nil, 10}, CompareNotBetween), "sort > ?", 1},
+		{driver.DBTypeMySQL, GenCons("name", "ab", CompareStartsWith), "name LIKE ? ESCAPE '\\\\'", 1},
+		{driver.DBTypeUxDB, GenCons("name", "ab", CompareILike), "name ILIKE ? ESCAPE '\\'", 1},
+		{driver.DBTypeVbDB, GenCons("name", "ab", CompareILike), "name ILIKE ? ESCAPE '\\'", 1},
+		{driver.DBTypeDmDB, GenCons("name", "ab", CompareILike), "UPPER(name) LIKE UPPER(?) ESCAPE '\\'", 1},
+		{driver.DBTypeMySQL, GenExists(sub), "EXISTS (" + sub.SQL + ")", 1},
+		{driver.DBTypeMySQL, GenNotExists(sub), "NOT EXISTS (" + sub.SQL + ")", 1},
+		{driver.DBTypeMySQL, GenRaw("sort+audit>?", 5), "(sort+audit>?)", 1},
+		{driver.DBTypeMySQL, GenNot(OrConstraint{Wrapper: []ConsWrapper{GenCons("a", 1, CompareEqual), GenCons("b", 2, CompareEqual)}}), "NOT ((a = ? OR b = ?))", 2},
+		{driver.DBTypeDmDB, GenCons("", (*SubQuery)(nil), CompareExists), "1=0", 0},
+		{driver.DBTypeDmDB, GenCons("", "SELECT 1", CompareNotExists), "1=1", 0},
+		{driver.DBTypeMySQL, GenCons("id", (*SubQuery)(nil), CompareIn), "1=0", 0},
+		{driver.DBTypeMySQL, GenNot(nil), "1=0", 0},
+	}
+	for _, item := range testdatas {
+		var build strings.Builder
+		params := genConsSQL(&build, item.dbType, item.cons)
+		if build.String() != item.expect || len(params) != item.params {
+			t.Fatalf("%s.sql=%s,params=%v,expect=%s", driver.GetDbName(item.dbType), build.String(), params, item.expect)
+		}
+	}
+	if v := GenCons("name", "ab", CompareEndsWith).Value; v != "%ab" {
+		t.Fatalf("ends with value=%v", v)
+	}
+}
+
+func TestGenConsWrapper_Order(t *testing.T) {
+	constraint := map[string]interface{}{"type": 1, "name": "a", "code": "c", "zz": 1, "audit": 0}
+	compareMatch := map[string]int{"name": CompareLike}
+	orMatch := map[string]interface{}{"code": true, "zz": true}
+	expect := " AND (audit = ? AND name LIKE ? ESCAPE '\\\\' AND type = ? AND (code = ? OR zz = ?))"
+	for i := 0; i < 20; i++ {
+		var build strings.Builder
+		GenWhereSQL(&build, GenConsWrapper(constraint, compareMatch, orMatch))
+		if build.String() != expect {
+			t.Fatalf("sql=%s,expect=%s", build.String(), expect)
+		}
+	}
+	expect = " AND (name LIKE ? ESCAPE '\\\\' AND type = ? AND audit = ? AND (code = ? OR zz = ?))"
+	for i := 0; i < 20; i++ {
+		var build strings.Builder
+		GenWhereSQL(&build, GenModelConsWrapper(&testdata.Algorithm{}, constraint, compareMatch, orMatch))
+		if build.String() != expect {
+			t.Fatalf("sql=%s,expect=%s", build.String(), expect)
+		}
+	}
+}
+
+func TestConsBuilder_Build(t *testing.T) {
+	cons := NewConsBuilder().
+		Str("name", "a", CompareLike).
+		Str("impl", "", CompareEqual).
+		Int("enable", -1, CompareEqual).
+		Int("audit", 0, CompareEqual).
+		Threshold("sort", 5, 10, CompareGreaterThan).
+		AddOr("type", 1, CompareEqual).
+		AddOr("type", 2, CompareEqual).
+		Time("createTime", nil, CompareGreaterThanOrEqual).
+		Add("code", []string{"a"}, CompareIn).
+		Build()
+	var build strings.Builder
+	params := GenWhereSQL(&build, cons)
+	expect := " AND (name LIKE ? ESCAPE '\\\\' AND enable = ? AND code IN (?) AND (type = ? OR type = ?))"
+	if build.String() != expect || len(params) != 5 {
+		t.Fatalf("sql=%s,params=%v,expect=%s", build.String(), params, expect)
+	}
+}
+
+func TestGenLikeCons(t *testing.T) {
+	testdatas := []struct {
+		cons   Constraint
+		expect string
+	}{
+		{GenCons("name", "100%", CompareLike), "%100\\%%"},
+		{GenCons("name", "a_b", CompareNotLike), "%a\\_b%"},
+		{GenCons("name", "c:\\x", CompareLike), "%c:\\\\x%"},
+		{GenLikeCons("name", "a%", CompareNotLike, LikeStartsWith), "a\\%%"},
+		{GenLikeCons("name", "_b", CompareLike, LikeEndsWith), "%\\_b"},
+		{GenCons("name", "ab", CompareEndsWith), "%ab"},
+	}
+	for _, item := range testdatas {
+		if item.cons.Value != item.expect {
+			t.Fatalf("value=%v,expect=%s", item.cons.Value, item.expect)
+		}
+	}
+	var build strings.Builder
+	GenDbWhereSQL(&build, driver.DBTypeDmDB, []ConsWrapper{GenLikeCons("name", "a", CompareNotLike, LikeContains)})
+	if build.String() != " AND (name NOT LIKE ? ESCAPE '\\')" {
+		t.Fatalf("sql=%s", build.String())
+	}
+	data, _ := json.Marshal(GenCons("name", "100%_\\", CompareLike))
+	if string(data) != `{"field":"name","op":"like","value":"100%_\\"}` {
+		t.Fatalf("json=%s", data)
+	}
+}
+
+func TestCheckCons(t *testing.T) {
+	sub := SubQuery{SQL: "SELECT 1 FROM T_ORG o WHERE o.id=t.orgId"}
+	valid := []ConsWrapper{GenExists(sub), GenCons("", &sub, CompareNotExists), GenCons("id", sub, CompareIn), GenNot(GenCons("a", 1, CompareEqual))}
+	if err := CheckCons(valid); err != nil {
+		t.Fatalf("valid err=%v", err)
+	}
+	invalid := []ConsWrapper{
+		GenCons("", (*SubQuery)(nil), CompareExists),
+		GenCons("", "SELECT 1", CompareNotExists),
+		GenExists(SubQuery{}),
+		GenCons("id", (*SubQuery)(nil), CompareNotIn),
+		GenNot(nil),
+		Or(GenCons("a", 1, CompareEqual), And(Not(nil))),
+	}
+	for _, v := range invalid {
+		if err := CheckCons([]ConsWrapper{v}); err == nil {
+			t.Fatalf("cons=%v must be rejected", v)
+		}
+	}
+	gm, c := fakeGorm(t, nil)
+	if _, err := gm.FindPageRows("T_TEST", 1, 10, []ConsWrapper{GenNot(nil)}, nil); err == nil || len(c.statements()) != 0 {
+		t.Fatalf("find err=%v,stmts=%v", err, c.statements())
+	}
+}
@@ -0,0 +1,162 @@
+package gorm
+
+import (
+	"database/sql"
+	"fmt"
+	"github.com/samber/lo"
+	"gitops.sudytech.cn/guolei/gorm/driver"
+	"go.uber.org/zap"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// CountOptions 总数查询选项
+type CountOptions struct {
+	Estimate bool          // 无查询条件时使用数据库统计信息估算总数，统计信息不可用时精确查询
+	Cap      int64         // 总数上限，超过时返回上限并标记Capped，0为不限制
+	TTL      time.Duration // 相同SQL及参数的总数缓存时间，0为不缓存
+}
+
+// Total 总数查询结果
+type Total struct {
+	Count     int64
+	Estimated bool // 统计信息估算值
+	Capped    bool // 实际总数超过上限
+}
+
+// String 超过上限时显示为 10000+
+func (t Total) String() string {
+	if t.Capped {
+		return fmt.Sprintf("%d+", t.Count)
+	}
+	return fmt.Sprint(t.Count)
+}
+
+func (t Total) limit(max int64) Total {
+	if max > 0 && t.Count > max {
+		return Total{Count: max, Estimated: t.Estimated, Capped: true}
+	}
+	return t
+}
+
+type countEntry struct {
+	total  Total
+	expire time.Time
+}
+
+var countCache sync.Map
+var countStores int64
+
+// cachedCount 缓存总数，key为连接、SQL、参数及选项
+func (gm *Gorm) cachedCount(sql string, params []interface{}, opts CountOptions, count func() (Total, error)) (Total, error) {
+	if opts.TTL <= 0 {
+		return count()
+	}
+	key := fmt.Sprintf("%p|%s|%v|%t|%d", gm.Option, sql, params, opts.Estimate, opts.Cap)
+	if v, ok := countCache.Load(key); ok {
+		if entry := v.(countEntry); time.Now().Before(entry.expire) {
+			return entry.total, nil
+		}
+		countCache.Delete(key)
+	}
+	total, err := count()
+	if err != nil {
+		return total, err
+	}
+	countCache.Store(key, countEntry{total: total, expire: time.Now().Add(opts.TTL)})
+	if atomic.AddInt64(&countStores, 1)%100 == 0 {
+		now := time.Now()
+		countCache.Range(func(k, v interface{}) bool {
+			if now.After(v.(countEntry).expire) {
+				countCache.Delete(k)
+			}
+			return true
+		})
+	}
+	return total, nil
+}
+
+// capCount 最多统计 max+1 条记录，超过max时返回max并标记Capped
+func (gm *Gorm) capCount(selectSQL string, params []interface{}, max int64) (Total, error) {
+	count, err := gm.QueryTotal(fmt.Sprintf("SELECT COUNT(*) FROM (SELECT 1 FROM (%s) s LIMIT %d) c", selectSQL, max+1), params...)
+	if err != nil {
+		return Total{}, err
+	}
+	return Total{Count: count}.limit(max), nil
+}
+
+// FindPageCount 按选项查询模型总数，条件同 FindPageTotal
+func (gm *Gorm) FindPageCount(model interface{}, cons []ConsWrapper, opts CountOptions) (Total, error) {
+	var build strings.Builder
+	build.WriteString(fmt.Sprintf("SELECT 1 FROM %s t WHERE 1=1", gm.GetTable(model)))
+	params, err := genWhereSQL(&build, gm.Option.DbType, cons)
+	if err != nil {
+		return Total{}, err
+	}
+	return gm.cachedCount(build.String(), params, opts, func() (Total, error) {
+		if opts.Estimate && len(cons) == 0 {
+			if count, err := gm.EstimateCount(model); err == nil && count >= 0 {
+				return Total{Count: count, Estimated: true}.limit(opts.Cap), nil
+			}
+		}
+		if opts.Cap > 0 {
+			return gm.capCount(build.String(), params, opts.Cap)
+		}
+		count, err := gm.FindPageTotal(model, cons)
+		return Total{Count: count}, err
+	})
+}
+
+// findPageSelectCount 自定义查询SQL的总数，不支持估算
+func (gm *Gorm) findPageSelectCount(selectSQL string, selectParams []interface{}, cons []ConsWrapper, opts CountOptions) (Total, error) {
+	var build strings.Builder
+	build.WriteString(selectSQL)
+	var params []interface{}
+	params = append(params, selectParams...)
+	where, err := genWhereSQL(&build, gm.Option.DbType, cons)
+	if err != nil {
+		return Total{}, err
+	}
+	params = append(params, where...)
+	return gm.cachedCount(build.String(), params, opts, func() (Total, error) {
+		if opts.Cap > 0 {
+			return gm.capCount(build.String(), params, opts.Cap)
+		}
+		count, err := gm.QueryTotal(GenCountSQL(build.String()), params...)
+		return Total{Count: count}, err
+	})
+}
+
+// EstimateCount 根据数据库统计信息估算表记录数，优炫、海量为reltuples，MySQL为TABLE_ROWS，达梦为NUM_ROWS，
+// 统计信息未收集时返回-1
+func (gm *Gorm) EstimateCount(model interface{}) (int64, error) {
+	table := tableName(model)
+	schema := gm.Option.Schema
+	var query string
+	var params []interface{}
+	switch gm.Option.DbType {
+	case driver.DBTypeUxDB, driver.DBTypeVbDB:
+		catalog := map[int]string{driver.DBTypeUxDB: "ux_catalog.ux_", driver.DBTypeVbDB: "pg_catalog.pg_"}[gm.Option.DbType]
+		query = fmt.Sprintf("SELECT CAST(reltuples AS BIGINT) FROM %sclass WHERE relname = ? AND relnamespace = (SELECT oid FROM %snamespace WHERE nspname = %s)",
+			catalog, catalog, lo.Ternary(schema == "", "CURRENT_SCHEMA()", "?"))
+	case driver.DBTypeDmDB:
+		query = fmt.Sprintf("SELECT CAST(NUM_ROWS AS BIGINT) FROM ALL_TABLES WHERE TABLE_NAME = ? AND OWNER = %s", lo.Ternary(schema == "", "USER", "?"))
+	default:
+		query = fmt.Sprintf("SELECT TABLE_ROWS FROM information_schema.TABLES WHERE TABLE_NAME = ? AND TABLE_SCHEMA = %s", lo.Ternary(schema == "", "DATABASE()", "?"))
+	}
+	params = append(params, table)
+	if schema != "" {
+		params = append(params, schema)
+	}
+	var count sql.NullInt64
+	if err := gm.DB.Raw(query, params...).Scan(&count).Error; err != nil {
+		zap.S().Errorf("estimate count:sql=%s,param=%v,err=%v", query, params, err)
+		return -1, err
+	}
+	if !count.Valid {
+		return -1, nil
+	}
+	return count.Int64, nil
+}
@@ -0,0 +1,61 @@
+package gorm
+
+import (
+	sqldriver "database/sql/driver"
+	"testing"
+	"time"
+)
+
+func TestTotal_Limit(t *testing.T) {
+	total := Total{Count: 12000}.limit(10000)
+	if !total.Capped || total.Count != 10000 || total.String() != "10000+" {
+		t.Fatalf("total=%+v", total)
+	}
+	if total = (Total{Count: 12}).limit(10000); total.Capped || total.String() != "12" {
+		t.Fatalf("total=%+v", total)
+	}
+	page := Total{Count: 100, Capped: true}.page(make([]Row, 10), 10, 10)
+	if page.TotalPages != 10 || !page.HasNext || !page.Capped {
+		t.Fatalf("page=%+v", page)
+	}
+}
+
+func TestGorm_CachedCount(t *testing.T) {
+	gm := &Gorm{Option: &Option{}}
+	calls := 0
+	count := func() (Total, error) {
+		calls++
+		return Total{Count: int64(calls)}, nil
+	}
+	opts := CountOptions{TTL: 50 * time.Millisecond}
+	for i := 0; i < 3; i++ {
+		if total, _ := gm.cachedCount("SELECT 1 FROM a", []interface{}{1}, opts, count); total.Count != 1 {
+			t.Fatalf("cached total=%+v", total)
+		}
+	}
+	if total, _ := gm.cachedCount("SELECT 1 FROM a", []interface{}{2}, opts, count); total.Count != 2 {
+		t.Fatalf("params must be part of the key,total=%+v", total)
+	}
+	time.Sleep(60 * time.Millisecond)
+	if total, _ := gm.cachedCount("SELECT 1 FROM a", []interface{}{1}, opts, count); total.Count != 3 {
+		t.Fatalf("expired total=%+v", total)
+	}
+	if total, _ := gm.cachedCount("SELECT 1 FROM a", []interface{}{1}, CountOptions{}, count); total.Count != 4 {
+		t.Fatalf("no ttl must not be cached,total=%+v", total)
+	}
+}
+
+func TestGorm_CapCount(t *testing.T) {
+	gm, c := fakeGorm(t, func(query string, args []sqldriver.Value) (*fakeResult, error) {
+		return &fakeResult{columns: []string{"count"}, rows: [][]sqldriver.Value{{int64(11)}}}, nil
+	})
+	// 自带LIMIT或UNION的查询整体作为子查询，上限在外层生效
+	total, err := gm.capCount("SELECT id FROM a UNION SELECT id FROM b LIMIT 100", nil, 10)
+	if err != nil || !total.Capped || total.Count != 10 {
+		t.Fatalf("total=%+v,err=%v", total, err)
+	}
+	expect := "SELECT COUNT(*) FROM (SELECT 1 FROM (SELECT id FROM a UNION SELECT id FROM b LIMIT 100) s LIMIT 11) c"
+	if stmts := c.statements(); len(stmts) != 1 || stmts[0] != expect {
+		t.Fatalf("stmts=%v", stmts)
+	}
+}
@@ -0,0 +1,256 @@
+package gorm
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/samber/lo"
+	"github.com/spf13/cast"
+	"gitops.sudytech.cn/guolei/gorm/driver"
+	"gitops.sudytech.cn/guolei/gorm/sys"
+	"regexp"
+	"strings"
+	"time"
+)
+
+const (
+	CursorNext = "n"
+	CursorPrev = "p"
+)
+
+// CursorPage 游标分页结果，Next/Prev为空表示没有后一页/前一页
+type CursorPage struct {
+	Rows []Row
+	Next string
+	Prev string
+}
+
+// cursorToken 游标内容，Keys为排序字段，用于校验游标与排序是否一致
+type cursorToken struct {
+	Dir    string        `json:"d"`
+	Keys   []string      `json:"k"`
+	Values []cursorValue `json:"v"`
+}
+
+// cursorValue 带类型的字段值，保证时间、整数等类型在解码后不变
+type cursorValue struct {
+	T string      `json:"t"`
+	V interface{} `json:"v"`
+}
+
+func toCursorValue(v interface{}) (cursorValue, error) {
+	switch v.(type) {
+	case nil:
+		return cursorValue{}, errors.New("游标排序字段值不能为空")
+	case time.Time:
+		return cursorValue{T: "t", V: v.(time.Time).Format(time.RFC3339Nano)}, nil
+	case *time.Time:
+		return toCursorValue(*v.(*time.Time))
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+		return cursorValue{T: "i", V: cast.ToInt64(v)}, nil
+	case float32, float64:
+		return cursorValue{T: "f", V: cast.ToFloat64(v)}, nil
+	case []byte:
+		return cursorValue{T: "s", V: string(v.([]byte))}, nil
+	default:
+		return cursorValue{T: "s", V: fmt.Sprint(v)}, nil
+	}
+}
+
+func (cv cursorValue) value() (interface{}, error) {
+	switch cv.T {
+	case "t":
+		return time.Parse(time.RFC3339Nano, cast.ToString(cv.V))
+	case "i":
+		return cast.ToInt64E(cv.V)
+	case "f":
+		return cast.ToFloat64E(cv.V)
+	case "s":
+		return cast.ToString(cv.V), nil
+	default:
+		return nil, fmt.Errorf("游标字段类型%s不支持", cv.T)
+	}
+}
+
+// cursorKey 返回排序字段在结果中的列名，去掉表别名及引号
+func cursorKey(name string) string {
+	if i := strings.LastIndex(name, "."); i != -1 {
+		name = name[i+1:]
+	}
+	return strings.Trim(strings.TrimSpace(name), "\"`")
+}
+
+// EncodeCursor 根据记录及排序字段生成游标
+func EncodeCursor(row Row, orders []QueryOrder, dir string) (string, error) {
+	token := cursorToken{Dir: dir}
+	for _, v := range orders {
+		key := cursorKey(v.FieldName)
+		cv, err := toCursorValue(row.GetInterface(key))
+		if err != nil {
+			return "", err
+		}
+		token.Keys = append(token.Keys, key)
+		token.Values = append(token.Values, cv)
+	}
+	data, err := json.Marshal(token)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+// DecodeCursor 解析游标，返回方向及各排序字段的值
+func DecodeCursor(cursor string, orders []QueryOrder) (string, []interface{}, error) {
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return "", nil, errors.New("游标格式错误")
+	}
+	var token cursorToken
+	if err = json.Unmarshal(data, &token); err != nil {
+		return "", nil, errors.New("游标格式错误")
+	}
+	if token.Dir != CursorNext && token.Dir != CursorPrev {
+		return "", nil, errors.New("游标方向错误")
+	}
+	if len(token.Keys) != len(orders) || len(token.Values) != len(orders) {
+		return "", nil, errors.New("游标与排序字段不一致")
+	}
+	var values []interface{}
+	for k, v := range orders {
+		if !strings.EqualFold(token.Keys[k], cursorKey(v.FieldName)) {
+			return "", nil, errors.New("游标与排序字段不一致")
+		}
+		value, err := token.Values[k].value()
+		if err != nil {
+			return "", nil, err
+		}
+		values = append(values, value)
+	}
+	return token.Dir, values, nil
+}
+
+// cursorFieldRegex 游标排序字段：字段名或 alias.字段名，允许 SafeOrders 加引号后的形式
+var cursorFieldRegex = regexp.MustCompile("^(?:[A-Za-z_]\\w*|\"(?:[^\"]|\"\")+\"|`(?:[^`]|``)+`)(?:\\.(?:[A-Za-z_]\\w*|\"(?:[^\"]|\"\")+\"|`(?:[^`]|``)+`))?$")
+
+// checkCursorOrders 校验游标排序字段，字段直接拼接到SQL中，只允许字段名形式
+func checkCursorOrders(orders []QueryOrder) error {
+	for _, v := range orders {
+		if !cursorFieldRegex.MatchString(v.FieldName) {
+			return fmt.Errorf("游标排序字段%s格式错误", v.FieldName)
+		}
+	}
+	return nil
+}
+
+// GenKeysetCons 生成游标条件，排序方向一致时MySQL及优炫、海量使用行比较 (a,b) > (?,?)，否则展开为 a > ? OR (a = ? AND b > ?)；
+// 排序字段须为字段名或 alias.字段名，来自请求时先经 SafeOrders 校验，否则返回错误
+func GenKeysetCons(dbType int, orders []QueryOrder, values []interface{}, backward bool) (Constraint, error) {
+	if err := checkCursorOrders(orders); err != nil {
+		return Constraint{}, err
+	}
+	if len(values) != len(orders) {
+		return Constraint{}, errors.New("游标与排序字段不一致")
+	}
+	gt := func(asc bool) string {
+		return lo.Ternary(asc != backward, ">", "<")
+	}
+	same := lo.EveryBy(orders, func(v QueryOrder) bool {
+		return v.Asc == orders[0].Asc
+	})
+	if same && dbType != driver.DBTypeDmDB && len(orders) > 1 {
+		names := lo.Map(orders, func(v QueryOrder, _ int) string {
+			return v.FieldName
+		})
+		marks := strings.TrimSuffix(strings.Repeat("?,", len(orders)), ",")
+		return GenRaw(fmt.Sprintf("(%s) %s (%s)", strings.Join(names, ","), gt(orders[0].Asc), marks), values...), nil
+	}
+	var build strings.Builder
+	var params []interface{}
+	for k, v := range orders {
+		build.WriteString(lo.Ternary(k == 0, "", " OR "))
+		if k > 0 {
+			build.WriteString("(")
+		}
+		for i := 0; i < k; i++ {
+			build.WriteString(fmt.Sprintf("%s = ? AND ", orders[i].FieldName))
+			params = append(params, values[i])
+		}
+		build.WriteString(fmt.Sprintf("%s %s ?", v.FieldName, gt(v.Asc)))
+		params = append(params, values[k])
+		if k > 0 {
+			build.WriteString(")")
+		}
+	}
+	return GenRaw(build.String(), params...), nil
+}
+
+// FindCursorList 游标分页查询，orders最后一个字段需唯一（如主键），cursor为空时查询第一页；
+// 排序字段不能为NULL，记录中排序字段为NULL时生成游标(EncodeCursor)返回错误，可为NULL的字段需使用 COALESCE 等处理后的视图
+func (gm *Gorm) FindCursorList(model interface{}, cursor string, size int32, cons []ConsWrapper, orders []QueryOrder) (*CursorPage, error) {
+	if len(orders) == 0 {
+		return nil, errors.New("游标分页需要指定排序字段")
+	}
+	if err := checkCursorOrders(orders); err != nil {
+		return nil, err
+	}
+	if size <= 0 {
+		return nil, errors.New("游标分页需要指定每页记录数")
+	}
+	backward := false
+	wrapper := append([]ConsWrapper{}, cons...)
+	if cursor != "" {
+		dir, values, err := DecodeCursor(cursor, orders)
+		if err != nil {
+			return nil, err
+		}
+		backward = dir == CursorPrev
+		keyset, err := GenKeysetCons(gm.Option.DbType, orders, values, backward)
+		if err != nil {
+			return nil, err
+		}
+		wrapper = append(wrapper, keyset)
+	}
+	qOrders := orders
+	if backward {
+		qOrders = lo.Map(orders, func(v QueryOrder, _ int) QueryOrder {
+			return QueryOrder{FieldName: v.FieldName, Asc: !v.Asc}
+		})
+	}
+	rows, err := gm.FindPageRows(model, 1, size+1, wrapper, qOrders)
+	if err != nil {
+		return nil, err
+	}
+	more := len(rows) > int(size)
+	if more {
+		rows = rows[0:size]
+	}
+	if backward {
+		rows = lo.Reverse(rows)
+	}
+	page := &CursorPage{Rows: rows}
+	if len(rows) == 0 {
+		return page, nil
+	}
+	if more || backward {
+		if page.Next, err = EncodeCursor(rows[len(rows)-1], orders, CursorNext); err != nil {
+			return nil, err
+		}
+	}
+	if (more && backward) || (!backward && cursor != "") {
+		if page.Prev, err = EncodeCursor(rows[0], orders, CursorPrev); err != nil {
+			return nil, err
+		}
+	}
+	return page, nil
+}
+
+// FindSafeCursorList 字段白名单校验后游标分页查询，非法字段返回 sys.PropNotAllowCode
+func (gm *Gorm) FindSafeCursorList(model interface{}, cursor string, size int32, cons []ConsWrapper, orders []QueryOrder) (*CursorPage, sys.IGormErr) {
+	nCons, nOrders, err := gm.SafeWrapper(model, cons, orders)
+	if err != nil {
+		return nil, err
+	}
+	page, fErr := gm.FindCursorList(model, cursor, size, nCons, nOrders)
+	return page, sys.ErrIF(fErr)
+}
@@ -0,0 +1,101 @@
+package gorm
+
+import (
+	sqldriver "database/sql/driver"
+	"gitops.sudytech.cn/guolei/gorm/driver"
+	"gitops.sudytech.cn/guolei/gorm/testdata"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCursor_EncodeDecode(t *testing.T) {
+	orders := []QueryOrder{{FieldName: "t.createTime", Asc: false}, {FieldName: "id", Asc: false}}
+	now := time.Date(2024, 1, 2, 3, 4, 5, 6, time.UTC)
+	cursor, err := EncodeCursor(NewRow(map[string]interface{}{"createTime": now, "id": int32(10)}), orders, CursorNext)
+	if err != nil {
+		t.Fatalf("encode.err=%v", err)
+	}
+	dir, values, err := DecodeCursor(cursor, orders)
+	if err != nil {
+		t.Fatalf("decode.err=%v", err)
+	}
+	if dir != CursorNext || len(values) != 2 || !values[0].(time.Time).Equal(now) || values[1] != int64(10) {
+		t.Fatalf("dir=%s,values=%v", dir, values)
+	}
+	if _, _, err = DecodeCursor(cursor, orders[0:1]); err == nil {
+		t.Fatalf("cursor with different orders must be rejected")
+	}
+	if _, _, err = DecodeCursor("abc", orders); err == nil {
+		t.Fatalf("invalid cursor must be rejected")
+	}
+	if _, err = EncodeCursor(NewRow(map[string]interface{}{"id": 1}), orders, CursorNext); err == nil {
+		t.Fatalf("null key must be rejected")
+	}
+}
+
+func TestGenKeysetCons(t *testing.T) {
+	orders := []QueryOrder{{FieldName: "createTime", Asc: false}, {FieldName: "id", Asc: false}}
+	values := []interface{}{"2024-01-01", 10}
+	testdatas := []struct {
+		dbType   int
+		orders   []QueryOrder
+		backward bool
+		expect   string
+		params   int
+	}{
+		{driver.DBTypeMySQL, orders, false, " AND (((createTime,id) < (?,?)))", 2},
+		{driver.DBTypeUxDB, orders, true, " AND (((createTime,id) > (?,?)))", 2},
+		{driver.DBTypeDmDB, orders, false, " AND ((createTime < ? OR (createTime = ? AND id < ?)))", 3},
+		{driver.DBTypeMySQL, []QueryOrder{{FieldName: "createTime", Asc: false}, {FieldName: "id", Asc: true}}, false, " AND ((createTime < ? OR (createTime = ? AND id > ?)))", 3},
+	}
+	for _, v := range testdatas {
+		keyset, err := GenKeysetCons(v.dbType, v.orders, values, v.backward)
+		if err != nil {
+			t.Fatalf("keyset.err=%v", err)
+		}
+		var build strings.Builder
+		params := GenDbWhereSQL(&build, v.dbType, []ConsWrapper{keyset})
+		if build.String() != v.expect || len(params) != v.params {
+			t.Fatalf("sql=%s,params=%v,expect=%s", build.String(), params, v.expect)
+		}
+	}
+	valid := []string{"t.createTime", `"t"."create""Time"`, "`t`.`createTime`", "_id"}
+	for _, v := range valid {
+		if _, err := GenKeysetCons(driver.DBTypeMySQL, []QueryOrder{{FieldName: v}}, values[0:1], false); err != nil {
+			t.Fatalf("field=%s,err=%v", v, err)
+		}
+	}
+	invalid := []string{"id) OR (1=1", "id,name", "a.b.c", "1id", `"a"b"`, ""}
+	for _, v := range invalid {
+		if _, err := GenKeysetCons(driver.DBTypeMySQL, []QueryOrder{{FieldName: v}}, values[0:1], false); err == nil {
+			t.Fatalf("field=%s must be rejected", v)
+		}
+	}
+	if _, err := GenKeysetCons(driver.DBTypeMySQL, orders, values[0:1], false); err == nil {
+		t.Fatalf("values must match orders")
+	}
+}
+
+func TestGorm_FindSafeCursorList(t *testing.T) {
+	gm, c := fakeGorm(t, func(query string, args []sqldriver.Value) (*fakeResult, error) {
+		return &fakeResult{columns: []string{"id", "code"}, rows: [][]sqldriver.Value{{int64(1), "a"}, {int64(2), "b"}}}, nil
+	})
+	if _, err := gm.FindSafeCursorList(&testdata.Algorithm{}, "", 1, nil, []QueryOrder{{FieldName: "id desc"}}); err == nil {
+		t.Fatalf("order not in columns must be rejected")
+	}
+	if _, err := gm.FindCursorList("T_TEST", "", 1, nil, []QueryOrder{{FieldName: "id desc"}}); err == nil {
+		t.Fatalf("invalid order must be rejected")
+	}
+	page, err := gm.FindSafeCursorList(&testdata.Algorithm{}, "", 1, nil, []QueryOrder{{FieldName: "code", Asc: true}, {FieldName: "ID", Asc: true}})
+	if err != nil || len(page.Rows) != 1 || page.Next == "" || page.Prev != "" {
+		t.Fatalf("page=%+v,err=%v", page, err)
+	}
+	if _, err = gm.FindSafeCursorList(&testdata.Algorithm{}, page.Next, 1, nil, []QueryOrder{{FieldName: "code", Asc: true}, {FieldName: "ID", Asc: true}}); err != nil {
+		t.Fatalf("next.err=%v", err)
+	}
+	stmts := c.statements()
+	if len(stmts) != 2 || !strings.Contains(stmts[1], "WHERE 1=1 AND (((`code`,`id`) > (?,?))) ORDER BY `code` ASC,`id` ASC") {
+		t.Fatalf("stmts=%v", stmts)
+	}
+}
@@ -1,6 +1,7 @@
 package gorm
 
 import (
+	"fmt"
 	"github.com/mitchellh/mapstructure"
 	"github.com/samber/lo"
 	"github.com/spf13/cast"
@@ -199,8 +200,10 @@ func toDataTime(v interface{}) (*timestamppb.Timestamp, error) {
 		return timestamppb.New(v.(time.Time)), nil
 	case []uint8:
 		return toTimestamp(string(v.([]uint8)))
-	default:
+	case string:
 		return toTimestamp(v.(string))
+	default:
+		return nil, fmt.Errorf("值%v类型不匹配", v)
 	}
 }
 
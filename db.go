@@ -1,6 +1,7 @@
 package gorm
 
 import (
+	"errors"
 	"fmt"
 	"github.com/samber/lo"
 	"gitops.sudytech.cn/guolei/gorm/driver"
@@ -16,11 +17,21 @@ type TranSQL struct {
 	Params []interface{}
 }
 
+// ToError 返回执行错误，忽略记录不存在，驱动错误按 ClassifyError 分类为 DbError
 func ToError(req *gorm.DB) error {
+	return toDbError(-1, req)
+}
+
+// toError 同 ToError，达梦无错误码的错误按错误信息分类
+func (gm *Gorm) toError(req *gorm.DB) error {
+	return toDbError(gm.Option.DbType, req)
+}
+
+func toDbError(dbType int, req *gorm.DB) error {
 	if req != nil {
 		if err := req.Error; err != nil {
-			if !strings.Contains(err.Error(), "record not found") {
-				return err
+			if !errors.Is(err, gorm.ErrRecordNotFound) && !strings.Contains(err.Error(), "record not found") {
+				return ClassifyError(dbType, err)
 			}
 		}
 	}
@@ -37,7 +48,7 @@ func (gm *Gorm) ExecSQL(sql string, params ...interface{}) error {
 			zap.S().Errorf("exec sql:src=%s,new=%s,param=%v,err=%v", sql, nSQL, params, err)
 		}
 	}
-	return ToError(resp)
+	return gm.toError(resp)
 }
 
 func (gm *Gorm) ExecuteSQL(sql string, params ...interface{}) (int64, error) {
@@ -52,22 +63,22 @@ func (gm *Gorm) ExecuteSQL(sql string, params ...interface{}) (int64, error) {
 			zap.S().Errorf("execute sql:src=%s,new=%s,param=%v,err=%v", sql, nSQL, params, err)
 		}
 	}
-	return rows, ToError(resp)
+	return rows, gm.toError(resp)
 }
 
+// TranSQL 事务执行SQL，提交后执行callback；已在事务中(WithTx)时以保存点执行，此时外层尚未提交，不支持callback；批量执行见 WithBatch
 func (gm *Gorm) TranSQL(sql []TranSQL, callback ...func() error) error {
+	if len(callback) > 0 && gm.InTransaction() {
+		return errors.New("事务中执行TranSQL不支持callback，需在外层事务提交后执行")
+	}
 	opt := gm.Option
 	exp := driver.Exp{DbType: opt.DbType, Schema: opt.Schema}
-	err := gm.DB.Transaction(func(tx *gorm.DB) error {
-		for _, v := range sql {
-			nSQL := exp.ExecSQL(v.SQL)
-			if err := tx.Exec(nSQL, v.Params...).Error; err != nil {
-				zap.S().Errorf("tran sql:src=%s,new=%s,param=%v,err=%v", v.SQL, nSQL, v.Params, err)
-				return err
-			}
-		}
-		return nil
+	err := gm.withRetry(gm.context(), func() error {
+		return gm.tranSQL(exp, sql, func(tx *gorm.DB) error {
+			return nil
+		})
 	})
+	err = ClassifyError(opt.DbType, err)
 	if err == nil {
 		for _, v := range callback {
 			if cErr := v(); cErr != nil {
@@ -79,25 +90,22 @@ func (gm *Gorm) TranSQL(sql []TranSQL, callback ...func() error) error {
 	return err
 }
 
+// TransactionSQL 事务执行SQL及callback，callback中可通过 WithTx(tx) 嵌套执行，内层出错时只回滚到保存点
 func (gm *Gorm) TransactionSQL(sql []TranSQL, callback ...func(tx *gorm.DB, explain driver.Exp) error) error {
 	opt := gm.Option
 	exp := driver.Exp{DbType: opt.DbType, Schema: opt.Schema}
-	return gm.DB.Transaction(func(tx *gorm.DB) error {
-		for _, v := range sql {
-			nSQL := exp.ExecSQL(v.SQL)
-			if err := tx.Exec(nSQL, v.Params...).Error; err != nil {
-				zap.S().Errorf("tran sql:src=%s,new=%s,param=%v,err=%v", v.SQL, nSQL, v.Params, err)
-				return err
-			}
-		}
-		for _, v := range callback {
-			if cErr := v(tx, exp); cErr != nil {
-				zap.S().Error("tran callback err:", v, cErr)
-				return cErr
+	err := gm.withRetry(gm.context(), func() error {
+		return gm.tranSQL(exp, sql, func(tx *gorm.DB) error {
+			for _, v := range callback {
+				if cErr := v(tx, exp); cErr != nil {
+					zap.S().Error("tran callback err:", v, cErr)
+					return cErr
+				}
 			}
-		}
-		return nil
+			return nil
+		})
 	})
+	return ClassifyError(opt.DbType, err)
 }
 
 func (gm *Gorm) QueryRow(sql string, params ...interface{}) ([]Row, error) {
@@ -111,7 +119,7 @@ func (gm *Gorm) QueryRow(sql string, params ...interface{}) ([]Row, error) {
 			zap.S().Errorf("query row:src=%s,new=%s,param=%v,err=%v", sql, nSQL, params, err)
 		}
 	}
-	return Rows(result), ToError(resp)
+	return Rows(result), gm.toError(resp)
 }
 
 func (gm *Gorm) QueryTotal(sql string, params ...interface{}) (int64, error) {
@@ -138,16 +146,12 @@ func (gm *Gorm) QueryTotal(sql string, params ...interface{}) (int64, error) {
 			zap.S().Errorf("query total:src=%s,new=%s,param=%v,err=%v", sql, nSQL, params, err)
 		}
 	}
-	return result, ToError(resp)
+	return result, gm.toError(resp)
 }
 
 func (gm *Gorm) QueryRows(pageNo int32, pageSize int32, sql string, params ...interface{}) ([]Row, error) {
 	var result []map[string]interface{}
-	if pageNo > 0 {
-		if pageSize > 0 {
-			sql = fmt.Sprintf("%s LIMIT %d,%d", sql, (pageNo-1)*pageSize, pageSize)
-		}
-	}
+	sql = pageSQL(sql, pageNo, pageSize)
 	opt := gm.Option
 	exp := driver.Exp{DbType: opt.DbType, Schema: opt.Schema}
 	nSQL := exp.QuerySQL(sql)
@@ -157,7 +161,17 @@ func (gm *Gorm) QueryRows(pageNo int32, pageSize int32, sql string, params ...in
 			zap.S().Errorf("query rows:src=%s,new=%s,param=%v,err=%v", sql, nSQL, params, err)
 		}
 	}
-	return Rows(result), ToError(resp)
+	return Rows(result), gm.toError(resp)
+}
+
+// pageSQL 追加分页，pageNo或pageSize为0时不分页
+func pageSQL(sql string, pageNo int32, pageSize int32) string {
+	if pageNo > 0 {
+		if pageSize > 0 {
+			sql = fmt.Sprintf("%s LIMIT %d,%d", sql, (pageNo-1)*pageSize, pageSize)
+		}
+	}
+	return sql
 }
 
 func (gm *Gorm) GetTable(model interface{}) string {
@@ -172,6 +186,14 @@ func (gm *Gorm) GetInsertSQL(model interface{}, data map[string]interface{}) *Tr
 	return gm.Option.GetInsertSQL(model, data)
 }
 
+func (gm *Gorm) GetBatchInsertSQL(model interface{}, data []map[string]interface{}) []TranSQL {
+	return gm.Option.GetBatchInsertSQL(model, data)
+}
+
+func (gm *Gorm) GetUpsertSQL(model interface{}, data map[string]interface{}, conflictColumns []string, updateColumns []string) *TranSQL {
+	return gm.Option.GetUpsertSQL(model, data, conflictColumns, updateColumns)
+}
+
 func (gm *Gorm) GetUpdateSQL(model interface{}, pks map[string]interface{}, data map[string]interface{}) *TranSQL {
 	return gm.Option.GetUpdateSQL(model, pks, data)
 }
@@ -180,17 +202,31 @@ func (gm *Gorm) FindPageTotal(model interface{}, cons []ConsWrapper) (int64, err
 	table := gm.GetTable(model)
 	var build strings.Builder
 	build.WriteString(fmt.Sprintf("SELECT COUNT(1) FROM %s t WHERE 1=1", table))
-	params := GenWhereSQL(&build, cons)
+	params, err := genWhereSQL(&build, gm.Option.DbType, cons)
+	if err != nil {
+		return 0, err
+	}
 	return gm.QueryTotal(build.String(), params...)
 }
 
 func (gm *Gorm) FindPageRows(model interface{}, pageNo int32, pageSize int32, cons []ConsWrapper, orders []QueryOrder) ([]Row, error) {
-	table := gm.GetTable(model)
+	sql, params, err := gm.modelSQL(model, cons, orders)
+	if err != nil {
+		return nil, err
+	}
+	return gm.QueryRows(pageNo, pageSize, sql, params...)
+}
+
+// modelSQL 模型查询SQL，不含分页
+func (gm *Gorm) modelSQL(model interface{}, cons []ConsWrapper, orders []QueryOrder) (string, []interface{}, error) {
 	var build strings.Builder
-	build.WriteString(fmt.Sprintf("SELECT t.* FROM %s t WHERE 1=1", table))
-	params := GenWhereSQL(&build, cons)
+	build.WriteString(fmt.Sprintf("SELECT t.* FROM %s t WHERE 1=1", gm.GetTable(model)))
+	params, err := genWhereSQL(&build, gm.Option.DbType, cons)
+	if err != nil {
+		return "", nil, err
+	}
 	GenOrderSQL(&build, orders)
-	return gm.QueryRows(pageNo, pageSize, build.String(), params...)
+	return build.String(), params, nil
 }
 
 func (gm *Gorm) FindPageSelectTotal(selectSQL string, selectParams []interface{}, cons []ConsWrapper) (int64, error) {
@@ -198,7 +234,11 @@ func (gm *Gorm) FindPageSelectTotal(selectSQL string, selectParams []interface{}
 	build.WriteString(selectSQL)
 	var params []interface{}
 	params = append(params, selectParams...)
-	params = append(params, GenWhereSQL(&build, cons)...)
+	where, err := genWhereSQL(&build, gm.Option.DbType, cons)
+	if err != nil {
+		return 0, err
+	}
+	params = append(params, where...)
 	return gm.QueryTotal(build.String(), params...)
 }
 
@@ -207,7 +247,11 @@ func (gm *Gorm) FindPageSelectRows(selectSQL string, selectParams []interface{},
 	build.WriteString(selectSQL)
 	var params []interface{}
 	params = append(params, selectParams...)
-	params = append(params, GenWhereSQL(&build, cons)...)
+	where, err := genWhereSQL(&build, gm.Option.DbType, cons)
+	if err != nil {
+		return nil, err
+	}
+	params = append(params, where...)
 	GenOrderSQL(&build, orders)
 	return gm.QueryRows(pageNo, pageSize, build.String(), params...)
 }
@@ -217,7 +261,11 @@ func (gm *Gorm) FindPageFromTotal(fromSQL string, fromParams []interface{}, cons
 	build.WriteString(fmt.Sprintf("SELECT COUNT(1) %s", fromSQL))
 	var params []interface{}
 	params = append(params, fromParams...)
-	params = append(params, GenWhereSQL(&build, cons)...)
+	where, err := genWhereSQL(&build, gm.Option.DbType, cons)
+	if err != nil {
+		return 0, err
+	}
+	params = append(params, where...)
 	return gm.QueryTotal(build.String(), params...)
 }
 
@@ -226,27 +274,40 @@ func (gm *Gorm) FindPageFromRows(fromSQL string, fromParams []interface{}, pageN
 	build.WriteString(fmt.Sprintf("SELECT * %s", fromSQL))
 	var params []interface{}
 	params = append(params, fromParams...)
-	params = append(params, GenWhereSQL(&build, cons)...)
+	where, err := genWhereSQL(&build, gm.Option.DbType, cons)
+	if err != nil {
+		return nil, err
+	}
+	params = append(params, where...)
 	GenOrderSQL(&build, orders)
 	return gm.QueryRows(pageNo, pageSize, build.String(), params...)
 }
 
 func (gm *Gorm) FindPageList(model interface{}, pageNo int32, pageSize int32, cons []ConsWrapper, orders []QueryOrder) ([]Row, int64, error) {
+	return findPageList(pageNo, pageSize, func() (int64, error) {
+		return gm.FindPageTotal(model, cons)
+	}, func() ([]Row, error) {
+		return gm.FindPageRows(model, pageNo, pageSize, cons, orders)
+	})
+}
+
+// findPageList 并发查询总数及记录，pageNo为0时不查询总数，pageSize为0时不查询记录
+func findPageList[T any](pageNo int32, pageSize int32, total func() (int64, error), rows func() ([]T, error)) ([]T, int64, error) {
 	var count int64
 	var err1, err2 error
-	var resp []Row
+	var resp []T
 	var wg sync.WaitGroup
 	wg.Add(2)
 	go func() {
 		defer wg.Done()
 		if pageNo != 0 {
-			count, err1 = gm.FindPageTotal(model, cons)
+			count, err1 = total()
 		}
 	}()
 	go func() {
 		defer wg.Done()
 		if pageSize != 0 {
-			resp, err2 = gm.FindPageRows(model, pageNo, pageSize, cons, orders)
+			resp, err2 = rows()
 		}
 	}()
 	wg.Wait()
@@ -254,56 +315,30 @@ func (gm *Gorm) FindPageList(model interface{}, pageNo int32, pageSize int32, co
 }
 
 func (gm *Gorm) FindPageFromList(fromSQL string, fromParams []interface{}, pageNo int32, pageSize int32, cons []ConsWrapper, orders []QueryOrder) ([]Row, int64, error) {
-	var count int64
-	var err1, err2 error
-	var resp []Row
-	var wg sync.WaitGroup
-	wg.Add(2)
-	go func() {
-		defer wg.Done()
-		if pageNo != 0 {
-			count, err1 = gm.FindPageFromTotal(fromSQL, fromParams, cons)
-		}
-	}()
-	go func() {
-		defer wg.Done()
-		if pageSize != 0 {
-			resp, err2 = gm.FindPageFromRows(fromSQL, fromParams, pageNo, pageSize, cons, orders)
-		}
-	}()
-	wg.Wait()
-	return resp, count, lo.Ternary(err1 != nil, err1, err2)
+	return findPageList(pageNo, pageSize, func() (int64, error) {
+		return gm.FindPageFromTotal(fromSQL, fromParams, cons)
+	}, func() ([]Row, error) {
+		return gm.FindPageFromRows(fromSQL, fromParams, pageNo, pageSize, cons, orders)
+	})
 }
 
 func (gm *Gorm) findPageSelectTotal(selectSQL string, selectParams []interface{}, cons []ConsWrapper) (int64, error) {
 	var build strings.Builder
-	sql := strings.ToUpper(selectSQL)
-	index := strings.Index(sql, "FROM ")
-	build.WriteString(fmt.Sprintf("SELECT COUNT(1) %s", selectSQL[index:]))
+	build.WriteString(selectSQL)
 	var params []interface{}
 	params = append(params, selectParams...)
-	params = append(params, GenWhereSQL(&build, cons)...)
-	return gm.QueryTotal(build.String(), params...)
+	where, err := genWhereSQL(&build, gm.Option.DbType, cons)
+	if err != nil {
+		return 0, err
+	}
+	params = append(params, where...)
+	return gm.QueryTotal(GenCountSQL(build.String()), params...)
 }
 
 func (gm *Gorm) FindPageSelectList(selectSQL string, selectParams []interface{}, pageNo int32, pageSize int32, cons []ConsWrapper, orders []QueryOrder) ([]Row, int64, error) {
-	var count int64
-	var err1, err2 error
-	var resp []Row
-	var wg sync.WaitGroup
-	wg.Add(2)
-	go func() {
-		defer wg.Done()
-		if pageNo != 0 {
-			count, err1 = gm.findPageSelectTotal(selectSQL, selectParams, cons)
-		}
-	}()
-	go func() {
-		defer wg.Done()
-		if pageSize != 0 {
-			resp, err2 = gm.FindPageSelectRows(selectSQL, selectParams, pageNo, pageSize, cons, orders)
-		}
-	}()
-	wg.Wait()
-	return resp, count, lo.Ternary(err1 != nil, err1, err2)
+	return findPageList(pageNo, pageSize, func() (int64, error) {
+		return gm.findPageSelectTotal(selectSQL, selectParams, cons)
+	}, func() ([]Row, error) {
+		return gm.FindPageSelectRows(selectSQL, selectParams, pageNo, pageSize, cons, orders)
+	})
 }
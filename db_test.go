@@ -1,7 +1,11 @@
 package gorm
 
 import (
+	"context"
+	"github.com/samber/lo"
 	"gitops.sudytech.cn/guolei/gorm/driver"
+	"gitops.sudytech.cn/guolei/gorm/testdata"
+	"gorm.io/gorm"
 	"testing"
 )
 
@@ -22,3 +26,65 @@ func TestGorm_TranSQL(t *testing.T) {
 		}
 	}
 }
+
+func TestGorm_QueryEach(t *testing.T) {
+	opts, _ := options()
+	for _, v := range opts {
+		gorm, err := v.GetInit()
+		if err != nil {
+			t.Fatalf("%s.connDB.err=%v", driver.GetDbName(v.DbType), err)
+		}
+		var count int
+		err = gorm.QueryEach(context.Background(), "SELECT * FROM T1", nil, func(row Row) error {
+			count++
+			return lo.Ternary(count == 2, ErrStopIteration, nil)
+		}, IterOptions{FetchSize: 1})
+		if err != nil || count > 2 {
+			t.Fatalf("%s.each.count=%d,err=%v", driver.GetDbName(v.DbType), count, err)
+		}
+	}
+}
+
+func TestGorm_GetByPk(t *testing.T) {
+	opts, _ := options()
+	for _, v := range opts {
+		gorm, err := v.GetInit()
+		if err != nil {
+			t.Fatalf("%s.connDB.err=%v", driver.GetDbName(v.DbType), err)
+		}
+		algs, _, err := FindPageAs[testdata.Algorithm](gorm, 1, 1, nil, nil)
+		if err != nil {
+			t.Fatalf("%s.page.err=%v", driver.GetDbName(v.DbType), err)
+		}
+		if len(algs) > 0 {
+			alg, err := GetByPk[testdata.Algorithm](gorm, algs[0].Id)
+			if err != nil || alg == nil || alg.Id != algs[0].Id {
+				t.Fatalf("%s.get.alg=%v,err=%v", driver.GetDbName(v.DbType), alg, err)
+			}
+		}
+	}
+}
+
+func TestGorm_NestedTransactionSQL(t *testing.T) {
+	opts, _ := options()
+	for _, v := range opts {
+		gm, err := v.GetInit()
+		if err != nil {
+			t.Fatalf("%s.connDB.err=%v", driver.GetDbName(v.DbType), err)
+		}
+		outer := []TranSQL{{SQL: "INSERT INTO T1 VALUES(?,?)", Params: []interface{}{"0112", "外层"}}}
+		inner := []TranSQL{{SQL: "INSERT INTO T1 VALUES(?,?)", Params: []interface{}{"0113", "内层"}}, {SQL: "INSERT INTO T_NOT_EXISTS VALUES(?)", Params: []interface{}{1}}}
+		var innerErr error
+		err = gm.TransactionSQL(outer, func(tx *gorm.DB, explain driver.Exp) error {
+			innerErr = gm.WithTx(tx).TransactionSQL(inner)
+			return nil
+		})
+		if err != nil || innerErr == nil {
+			t.Fatalf("%s.nested.err=%v,innerErr=%v", driver.GetDbName(v.DbType), err, innerErr)
+		}
+		total, err := gm.QueryTotal("SELECT COUNT(1) FROM T1 WHERE id = ?", "0113")
+		if err != nil || total != 0 {
+			t.Fatalf("%s.inner must be rolled back,total=%d,err=%v", driver.GetDbName(v.DbType), total, err)
+		}
+	}
+}
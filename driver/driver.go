@@ -39,6 +39,36 @@ func GetDbDisplayName(dbType int) string {
 	}
 }
 
+// GetMaxInSize IN列表最大元素个数，超过后拆分为多组，0为不限制
+func GetMaxInSize(dbType int) int {
+	switch dbType {
+	case DBTypeDmDB:
+		return 1000
+	default:
+		return 0
+	}
+}
+
+// GetMaxParams 单条SQL最大参数个数
+func GetMaxParams(dbType int) int {
+	switch dbType {
+	case DBTypeDmDB:
+		return 32767
+	default:
+		return 65535
+	}
+}
+
+// GetLikeEscape Like的ESCAPE子句，统一使用\作为转义字符，MySQL字符串中\需要转义
+func GetLikeEscape(dbType int) string {
+	switch dbType {
+	case DBTypeUxDB, DBTypeDmDB, DBTypeVbDB:
+		return " ESCAPE '\\'"
+	default:
+		return " ESCAPE '\\\\'"
+	}
+}
+
 type Exp struct {
 	DbType int
 	Schema string
@@ -80,6 +110,21 @@ func (exp Exp) TableName(tableName string) string {
 	return tableName
 }
 
+// Quote 按数据库类型对标识符加引号，支持 alias.column 形式
+func (exp Exp) Quote(name string) string {
+	var build strings.Builder
+	for k, v := range strings.Split(name, ".") {
+		build.WriteString(lo.Ternary(k == 0, "", "."))
+		switch exp.DbType {
+		case DBTypeUxDB, DBTypeDmDB, DBTypeVbDB:
+			build.WriteString("\"" + strings.ReplaceAll(v, "\"", "\"\"") + "\"")
+		default:
+			build.WriteString("`" + strings.ReplaceAll(v, "`", "``") + "`")
+		}
+	}
+	return build.String()
+}
+
 var regexQ = []*regexp.Regexp{regexQ1, regexQ2}
 var regexQ1 = regexp.MustCompile("[ ,][TtSsVv]_\\w+[( ]")    // 用于处理SQL中T_、t_、V_、v_、S_、s_前缀的表名
 var regexQ2 = regexp.MustCompile("\\.\\w+[)!=+-><,&|^*/% ]") // 用于处理SQL中.前缀的字段名，即表(别名).前缀的字段
@@ -230,13 +230,11 @@ func (dialector Dialector) getSchemaCustomType(field *schema.Field) string {
 }
 
 func (dialector Dialector) SavePoint(tx *gorm.DB, name string) error {
-	tx.Exec("SAVEPOINT " + name)
-	return nil
+	return tx.Exec("SAVEPOINT " + name).Error
 }
 
 func (dialector Dialector) RollbackTo(tx *gorm.DB, name string) error {
-	tx.Exec("ROLLBACK TO SAVEPOINT " + name)
-	return nil
+	return tx.Exec("ROLLBACK TO SAVEPOINT " + name).Error
 }
 
 func getSerialDatabaseType(s string) (dbType string, ok bool) {
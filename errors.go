@@ -0,0 +1,270 @@
+package gorm
+
+import (
+	"context"
+	sqldriver "database/sql/driver"
+	"errors"
+	"github.com/go-sql-driver/mysql"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/samber/lo"
+	"gitops.sudytech.cn/guolei/gorm/driver"
+	"gitops.sudytech.cn/guolei/gorm/sys"
+	"net"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	ErrDuplicateKey = errors.New("duplicate key")
+	ErrForeignKey   = errors.New("foreign key violation")
+	ErrNotNull      = errors.New("not null violation")
+	ErrCheck        = errors.New("check violation")
+	ErrDeadlock     = errors.New("deadlock")
+	ErrTimeout      = errors.New("timeout")
+	ErrConnection   = errors.New("connection error")
+)
+
+// errorCodes 分类错误对应的sys错误码
+var errorCodes = map[error]int32{
+	ErrDuplicateKey: sys.DuplicateCode,
+	ErrForeignKey:   sys.ForeignKeyCode,
+	ErrNotNull:      sys.NotNullCode,
+	ErrCheck:        sys.CheckCode,
+	ErrDeadlock:     sys.DeadlockCode,
+	ErrTimeout:      sys.TimeoutCode,
+	ErrConnection:   sys.ConnectionCode,
+}
+
+// DbError 分类后的数据库错误，errors.Is 可匹配 Kind，errors.As 可取得驱动原始错误，同时实现 sys.IGormErr
+type DbError struct {
+	Kind       error  // ErrDuplicateKey 等
+	Code       string // 驱动错误码
+	Constraint string // 约束名，驱动未提供时为空
+	Column     string // 字段名，驱动未提供时为空
+	Lock       bool   // 锁冲突导致（死锁、锁等待超时、序列化失败），可重试
+	Err        error
+}
+
+func (e *DbError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *DbError) Unwrap() error {
+	return e.Err
+}
+
+func (e *DbError) Is(target error) bool {
+	return target == e.Kind
+}
+
+func (e *DbError) GetCode() int32 {
+	return errorCodes[e.Kind]
+}
+
+func (e *DbError) GetError() error {
+	return e
+}
+
+func (e *DbError) GetMessage() string {
+	return e.Error()
+}
+
+// ToGormErr 转换为 sys.IGormErr，分类错误使用对应的错误码，其它为 sys.FailCode
+func ToGormErr(err error) sys.IGormErr {
+	if err == nil {
+		return nil
+	}
+	var dbErr *DbError
+	if errors.As(ClassifyError(-1, err), &dbErr) {
+		return dbErr
+	}
+	return sys.NewErr(err)
+}
+
+// ClassifyError 按驱动错误码分类数据库错误，无法分类时返回原错误；dbType为达梦时无错误码的错误按错误信息分类，其它情况传-1
+func ClassifyError(dbType int, err error) error {
+	if err == nil {
+		return nil
+	}
+	var dbErr *DbError
+	if errors.As(err, &dbErr) {
+		return err
+	}
+	var myErr *mysql.MySQLError
+	if errors.As(err, &myErr) {
+		return classifyMySQL(myErr, err)
+	}
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		return classifyPg(pgErr, err)
+	}
+	if code, ok := dmErrCode(err); ok {
+		return classifyDm(code, err)
+	}
+	if pgconn.Timeout(err) {
+		return &DbError{Kind: ErrTimeout, Err: err}
+	}
+	if errors.Is(err, sqldriver.ErrBadConn) || errors.Is(err, mysql.ErrInvalidConn) || pgconn.SafeToRetry(err) {
+		return &DbError{Kind: ErrConnection, Err: err}
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return &DbError{Kind: lo.Ternary[error](netErr.Timeout(), ErrTimeout, ErrConnection), Err: err}
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return &DbError{Kind: ErrTimeout, Err: err}
+	}
+	if dbType == driver.DBTypeDmDB {
+		if kind, lock := classifyDmMessage(err.Error()); kind != nil {
+			return &DbError{Kind: kind, Lock: lock, Err: err}
+		}
+	}
+	return err
+}
+
+var regexMyKey = regexp.MustCompile("for key '([^']+)'")
+var regexMyConstraint = regexp.MustCompile("CONSTRAINT `([^`]+)`")
+var regexMyColumn = regexp.MustCompile("(?:Column|Field) '([^']+)'")
+var regexMyCheck = regexp.MustCompile("[Cc]heck constraint '([^']+)'")
+
+func classifyMySQL(myErr *mysql.MySQLError, err error) error {
+	dbErr := &DbError{Code: strconv.Itoa(int(myErr.Number)), Err: err}
+	match := func(regex *regexp.Regexp) string {
+		if m := regex.FindStringSubmatch(myErr.Message); len(m) == 2 {
+			return m[1]
+		}
+		return ""
+	}
+	switch myErr.Number {
+	case 1062, 1586:
+		dbErr.Kind, dbErr.Constraint = ErrDuplicateKey, match(regexMyKey)
+		// MySQL 8 为 表名.索引名
+		if i := strings.LastIndex(dbErr.Constraint, "."); i != -1 {
+			dbErr.Constraint = dbErr.Constraint[i+1:]
+		}
+	case 1451, 1452, 1216, 1217:
+		dbErr.Kind, dbErr.Constraint = ErrForeignKey, match(regexMyConstraint)
+	case 1048, 1364:
+		dbErr.Kind, dbErr.Column = ErrNotNull, match(regexMyColumn)
+	case 3819:
+		dbErr.Kind, dbErr.Constraint = ErrCheck, match(regexMyCheck)
+	case 1213:
+		dbErr.Kind, dbErr.Lock = ErrDeadlock, true
+	case 1205:
+		dbErr.Kind, dbErr.Lock = ErrTimeout, true
+	case 3024, 1317:
+		dbErr.Kind = ErrTimeout
+	case 1040, 1043, 1045, 1129, 1130, 2002, 2003, 2006, 2013:
+		dbErr.Kind = ErrConnection
+	default:
+		return err
+	}
+	return dbErr
+}
+
+func classifyPg(pgErr *pgconn.PgError, err error) error {
+	dbErr := &DbError{Code: pgErr.Code, Constraint: pgErr.ConstraintName, Column: pgErr.ColumnName, Err: err}
+	switch pgErr.Code {
+	case "23505":
+		dbErr.Kind = ErrDuplicateKey
+	case "23503":
+		dbErr.Kind = ErrForeignKey
+	case "23502":
+		dbErr.Kind = ErrNotNull
+	case "23514":
+		dbErr.Kind = ErrCheck
+	case "40001", "40P01":
+		dbErr.Kind, dbErr.Lock = ErrDeadlock, true
+	case "55P03":
+		dbErr.Kind, dbErr.Lock = ErrTimeout, true
+	case "57014":
+		dbErr.Kind = ErrTimeout
+	default:
+		if strings.HasPrefix(pgErr.Code, "08") || pgErr.Code == "57P01" || pgErr.Code == "57P03" {
+			dbErr.Kind = ErrConnection
+		} else {
+			return err
+		}
+	}
+	return dbErr
+}
+
+// DmDeadlockCodes 达梦死锁错误码，DmLockTimeoutCodes 达梦锁超时错误码，DmDuplicateCodes 达梦唯一约束错误码
+var (
+	DmDeadlockCodes    = []int64{-6402}
+	DmLockTimeoutCodes = []int64{-6403}
+	DmDuplicateCodes   = []int64{-6602}
+)
+
+func classifyDm(code int64, err error) error {
+	dbErr := &DbError{Code: strconv.FormatInt(code, 10), Err: err}
+	switch {
+	case containsCode(DmDeadlockCodes, code):
+		dbErr.Kind, dbErr.Lock = ErrDeadlock, true
+	case containsCode(DmLockTimeoutCodes, code):
+		dbErr.Kind, dbErr.Lock = ErrTimeout, true
+	case containsCode(DmDuplicateCodes, code):
+		dbErr.Kind = ErrDuplicateKey
+	default:
+		if dbErr.Kind, dbErr.Lock = classifyDmMessage(err.Error()); dbErr.Kind == nil {
+			return err
+		}
+	}
+	return dbErr
+}
+
+// dmErrCode 读取达梦驱动错误的ErrCode字段，避免直接依赖驱动包
+func dmErrCode(err error) (int64, bool) {
+	for ; err != nil; err = errors.Unwrap(err) {
+		v := reflect.ValueOf(err)
+		for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+			if v.IsNil() {
+				break
+			}
+			v = v.Elem()
+		}
+		if v.Kind() != reflect.Struct {
+			continue
+		}
+		if f := v.FieldByName("ErrCode"); f.IsValid() {
+			switch f.Kind() {
+			case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+				return f.Int(), true
+			}
+		}
+	}
+	return 0, false
+}
+
+func containsCode(codes []int64, code int64) bool {
+	for _, v := range codes {
+		if v == code {
+			return true
+		}
+	}
+	return false
+}
+
+// classifyDmMessage 按达梦错误信息分类，返回分类及是否为锁冲突
+func classifyDmMessage(msg string) (error, bool) {
+	switch {
+	case strings.Contains(msg, "唯一性约束"):
+		return ErrDuplicateKey, false
+	case strings.Contains(msg, "引用约束") || strings.Contains(msg, "外键"):
+		return ErrForeignKey, false
+	case strings.Contains(msg, "非空") || strings.Contains(msg, "不能为空"):
+		return ErrNotNull, false
+	case strings.Contains(msg, "CHECK约束") || strings.Contains(msg, "检查约束"):
+		return ErrCheck, false
+	case strings.Contains(msg, "死锁") || strings.Contains(strings.ToLower(msg), "deadlock"):
+		return ErrDeadlock, true
+	case strings.Contains(msg, "超时"):
+		return ErrTimeout, strings.Contains(msg, "锁超时")
+	case strings.Contains(msg, "网络") || strings.Contains(msg, "连接"):
+		return ErrConnection, false
+	default:
+		return nil, false
+	}
+}
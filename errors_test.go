@@ -0,0 +1,103 @@
+package gorm
+
+import (
+	sqldriver "database/sql/driver"
+	"errors"
+	"fmt"
+	"github.com/go-sql-driver/mysql"
+	"github.com/jackc/pgx/v5/pgconn"
+	"gitops.sudytech.cn/guolei/gorm/driver"
+	"gitops.sudytech.cn/guolei/gorm/sys"
+	"gitops.sudytech.cn/guolei/gorm/testdata"
+	"testing"
+)
+
+func TestClassifyError(t *testing.T) {
+	testdatas := []struct {
+		dbType     int
+		err        error
+		kind       error
+		constraint string
+		column     string
+	}{
+		{-1, &mysql.MySQLError{Number: 1062, Message: "Duplicate entry 'a' for key 'T_TEST_ALGORITHM.idx_code'"}, ErrDuplicateKey, "idx_code", ""},
+		{-1, &mysql.MySQLError{Number: 1452, Message: "Cannot add or update a child row: a foreign key constraint fails (`db`.`t`, CONSTRAINT `fk_org` FOREIGN KEY (`orgId`) REFERENCES `org` (`id`))"}, ErrForeignKey, "fk_org", ""},
+		{-1, &mysql.MySQLError{Number: 1048, Message: "Column 'name' cannot be null"}, ErrNotNull, "", "name"},
+		{-1, &mysql.MySQLError{Number: 3819, Message: "Check constraint 'chk_sort' is violated."}, ErrCheck, "chk_sort", ""},
+		{-1, fmt.Errorf("exec:%w", &mysql.MySQLError{Number: 1213}), ErrDeadlock, "", ""},
+		{-1, &pgconn.PgError{Code: "23505", ConstraintName: "idx_code"}, ErrDuplicateKey, "idx_code", ""},
+		{-1, &pgconn.PgError{Code: "23502", ColumnName: "name"}, ErrNotNull, "", "name"},
+		{-1, &pgconn.PgError{Code: "23503", ConstraintName: "fk_org"}, ErrForeignKey, "fk_org", ""},
+		{-1, &pgconn.PgError{Code: "57014"}, ErrTimeout, "", ""},
+		{-1, &pgconn.PgError{Code: "08006"}, ErrConnection, "", ""},
+		{-1, &dmError{ErrCode: -6602, ErrText: "违反表[T1]唯一性约束"}, ErrDuplicateKey, "", ""},
+		{-1, &dmError{ErrCode: -1, ErrText: "违反引用约束[FK_ORG]"}, ErrForeignKey, "", ""},
+		{driver.DBTypeDmDB, errors.New("违反列[NAME]非空约束"), ErrNotNull, "", ""},
+		{-1, mysql.ErrInvalidConn, ErrConnection, "", ""},
+	}
+	for k, v := range testdatas {
+		err := ClassifyError(v.dbType, v.err)
+		var dbErr *DbError
+		if !errors.As(err, &dbErr) || !errors.Is(err, v.kind) || dbErr.Constraint != v.constraint || dbErr.Column != v.column {
+			t.Fatalf("%d.err=%v,classified=%+v", k, v.err, dbErr)
+		}
+		if !errors.Is(err, v.err) && errors.Unwrap(v.err) == nil {
+			t.Fatalf("%d.original error must be wrapped", k)
+		}
+	}
+	if err := ClassifyError(-1, &mysql.MySQLError{Number: 1146}); errors.As(err, new(*DbError)) {
+		t.Fatalf("unknown code must not be classified")
+	}
+	if err := ClassifyError(-1, errors.New("违反列[NAME]非空约束")); errors.As(err, new(*DbError)) {
+		t.Fatalf("message must only be classified for dm")
+	}
+}
+
+func TestToGormErr(t *testing.T) {
+	gErr := ToGormErr(&pgconn.PgError{Severity: "ERROR", Code: "23505", Message: "duplicate key value"})
+	if gErr.GetCode() != sys.DuplicateCode || gErr.GetMessage() != "ERROR: duplicate key value (SQLSTATE 23505)" {
+		t.Fatalf("code=%d,message=%s", gErr.GetCode(), gErr.GetMessage())
+	}
+	if gErr = ToGormErr(errors.New("other")); gErr.GetCode() != sys.FailCode {
+		t.Fatalf("code=%d", gErr.GetCode())
+	}
+	if ToGormErr(nil) != nil {
+		t.Fatalf("nil error must be nil")
+	}
+}
+
+func TestGorm_TranSQLDuplicateKey(t *testing.T) {
+	gm, _ := fakeGorm(t, func(query string, args []sqldriver.Value) (*fakeResult, error) {
+		return nil, &mysql.MySQLError{Number: 1062, Message: "Duplicate entry 'a' for key 'idx_code'"}
+	})
+	sqls := []TranSQL{{SQL: "INSERT INTO T_TEST(code) VALUES(?)", Params: []interface{}{"a"}}}
+	if err := gm.TranSQL(sqls); !errors.Is(err, ErrDuplicateKey) {
+		t.Fatalf("tran err=%v", err)
+	}
+	if err := gm.TransactionSQL(sqls); !errors.Is(err, ErrDuplicateKey) {
+		t.Fatalf("transaction err=%v", err)
+	}
+	if err := gm.WithBatch(BatchOptions{Size: 10}).TranSQL(sqls); !errors.Is(err, ErrDuplicateKey) {
+		t.Fatalf("batch err=%v", err)
+	}
+	_, _, gErr := gm.FindSafePageList(&testdata.Algorithm{}, 1, 10, nil, nil)
+	if gErr == nil || gErr.GetCode() != sys.DuplicateCode {
+		t.Fatalf("gorm err=%v", gErr)
+	}
+	if gErr = sys.ErrIF(fmt.Errorf("wrap:%w", ClassifyError(-1, &mysql.MySQLError{Number: 1213}))); gErr.GetCode() != sys.DeadlockCode {
+		t.Fatalf("wrapped code=%d", gErr.GetCode())
+	}
+}
+
+func TestGorm_ToErrorDm(t *testing.T) {
+	gm, _ := fakeGorm(t, func(query string, args []sqldriver.Value) (*fakeResult, error) {
+		return nil, errors.New("违反唯一性约束[IDX_CODE]")
+	})
+	if err := gm.ExecSQL("UPDATE T_TEST SET code=?", "a"); errors.Is(err, ErrDuplicateKey) {
+		t.Fatalf("mysql must not classify by message,err=%v", err)
+	}
+	gm.Option.DbType = driver.DBTypeDmDB
+	if err := gm.ExecSQL("UPDATE T_TEST SET code=?", "a"); !errors.Is(err, ErrDuplicateKey) {
+		t.Fatalf("dm err=%v", err)
+	}
+}
@@ -0,0 +1,343 @@
+package gorm
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"github.com/samber/lo"
+	"gitops.sudytech.cn/guolei/gorm/sys"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	ExportCSV   = "csv"
+	ExportJSONL = "jsonl"
+	ExportXLSX  = "xlsx"
+)
+
+// ExportColumn 导出列，Key为结果集中的列名（忽略大小写），Title为表头
+type ExportColumn struct {
+	Key    string
+	Title  string
+	Layout string            // 时间格式，为空时使用 ExportOptions.TimeLayout
+	Bool   bool              // 按布尔值输出为 TrueText/FalseText，0为假
+	Labels map[string]string // 值对应的显示名称
+}
+
+// ExportOptions 导出选项
+type ExportOptions struct {
+	Format     string         // csv、jsonl、xlsx
+	Columns    []ExportColumn // 为空时导出结果集全部列
+	TimeLayout string         // 默认 2006-01-02 15:04:05
+	TrueText   string         // 默认 是
+	FalseText  string         // 默认 否
+	Sheet      string         // xlsx工作表名称，默认 Sheet1
+	BOM        bool           // csv写入UTF-8 BOM，便于Excel打开
+	NoHeader   bool           // csv、xlsx不输出表头
+	Iter       IterOptions
+}
+
+// ModelExportColumns 根据模型标签生成导出列，表头为json名，sorm枚举 enumeration:0=禁用,1=启用 作为显示名称
+func ModelExportColumns(model interface{}) []ExportColumn {
+	var columns []ExportColumn
+	for _, tag := range sys.GetTags(model) {
+		if g := tag.GormP; g != nil && g.Column != "" {
+			column := ExportColumn{Key: g.Column, Title: lo.Ternary(tag.Json != "", tag.Json, g.Column)}
+			if labels := tag.SormP.EnumLabels(); len(labels) > 0 {
+				column.Labels = labels
+			}
+			columns = append(columns, column)
+		}
+	}
+	return columns
+}
+
+// Export 流式导出查询结果，返回导出的记录数；查询出错时不写入任何内容，写入中途出错时仍会关闭导出文件
+func (gm *Gorm) Export(ctx context.Context, w io.Writer, opts ExportOptions, sql string, params ...interface{}) (count int64, err error) {
+	if !lo.Contains([]string{ExportCSV, ExportJSONL, ExportXLSX, ""}, strings.ToLower(opts.Format)) {
+		return 0, fmt.Errorf("导出格式%s不支持", opts.Format)
+	}
+	it, err := gm.QueryIter(ctx, opts.Iter, sql, params...)
+	if err != nil {
+		return 0, err
+	}
+	defer it.Close()
+	columns := opts.Columns
+	if len(columns) == 0 {
+		names, err := it.Columns()
+		if err != nil {
+			return 0, err
+		}
+		for _, v := range names {
+			columns = append(columns, ExportColumn{Key: v, Title: v})
+		}
+	}
+	ew, err := newExportWriter(w, opts)
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		if closeErr := ew.close(); err == nil {
+			err = closeErr
+		}
+	}()
+	keys := lo.Map(columns, func(v ExportColumn, _ int) string {
+		return v.Key
+	})
+	if !opts.NoHeader {
+		if err = ew.header(lo.Map(columns, func(v ExportColumn, _ int) string {
+			return lo.Ternary(v.Title != "", v.Title, v.Key)
+		})); err != nil {
+			return 0, err
+		}
+	}
+	values := make([]interface{}, len(columns))
+	for it.Next() {
+		row := it.Row()
+		for k, v := range columns {
+			values[k] = v.format(row.GetInterface(v.Key), opts)
+		}
+		if err = ew.row(keys, values); err != nil {
+			return count, err
+		}
+		count++
+	}
+	return count, it.Err()
+}
+
+// ExportPage 按条件和排序流式导出模型数据，未指定列时使用 ModelExportColumns
+func (gm *Gorm) ExportPage(ctx context.Context, w io.Writer, opts ExportOptions, model interface{}, cons []ConsWrapper, orders []QueryOrder) (int64, error) {
+	sql, params, err := gm.modelSQL(model, cons, orders)
+	if err != nil {
+		return 0, err
+	}
+	if len(opts.Columns) == 0 {
+		if _, ok := model.(string); !ok {
+			opts.Columns = ModelExportColumns(model)
+		}
+	}
+	return gm.Export(ctx, w, opts, sql, params...)
+}
+
+// format 按列设置格式化字段值，nil保持为nil
+func (c ExportColumn) format(v interface{}, opts ExportOptions) interface{} {
+	if v == nil {
+		return nil
+	}
+	if b, ok := v.([]byte); ok {
+		v = string(b)
+	}
+	if len(c.Labels) > 0 {
+		if label, ok := c.Labels[fmt.Sprint(v)]; ok {
+			return label
+		}
+	}
+	switch v.(type) {
+	case bool:
+		return lo.Ternary(v.(bool), lo.Ternary(opts.TrueText != "", opts.TrueText, "是"), lo.Ternary(opts.FalseText != "", opts.FalseText, "否"))
+	case time.Time:
+		layout := lo.Ternary(c.Layout != "", c.Layout, opts.TimeLayout)
+		return v.(time.Time).Format(lo.Ternary(layout != "", layout, dateTimeFormatPattern))
+	case *time.Time:
+		return c.format(*v.(*time.Time), opts)
+	}
+	if c.Bool {
+		return c.format(fmt.Sprint(v) != "0" && fmt.Sprint(v) != "" && !strings.EqualFold(fmt.Sprint(v), "false"), opts)
+	}
+	return v
+}
+
+type exportWriter interface {
+	header(titles []string) error
+	row(keys []string, values []interface{}) error
+	close() error
+}
+
+func newExportWriter(w io.Writer, opts ExportOptions) (exportWriter, error) {
+	switch strings.ToLower(opts.Format) {
+	case ExportCSV, "":
+		if opts.BOM {
+			if _, err := w.Write([]byte("\xEF\xBB\xBF")); err != nil {
+				return nil, err
+			}
+		}
+		return &csvWriter{w: csv.NewWriter(w)}, nil
+	case ExportJSONL:
+		return &jsonlWriter{w: bufio.NewWriter(w)}, nil
+	case ExportXLSX:
+		return newXlsxWriter(w, lo.Ternary(opts.Sheet != "", opts.Sheet, "Sheet1"))
+	default:
+		return nil, fmt.Errorf("导出格式%s不支持", opts.Format)
+	}
+}
+
+type csvWriter struct {
+	w *csv.Writer
+}
+
+func (cw *csvWriter) header(titles []string) error {
+	return cw.w.Write(titles)
+}
+
+func (cw *csvWriter) row(_ []string, values []interface{}) error {
+	record := make([]string, len(values))
+	for k, v := range values {
+		if v != nil {
+			record[k] = fmt.Sprint(v)
+		}
+	}
+	return cw.w.Write(record)
+}
+
+func (cw *csvWriter) close() error {
+	cw.w.Flush()
+	return cw.w.Error()
+}
+
+type jsonlWriter struct {
+	w   *bufio.Writer
+	buf bytes.Buffer
+}
+
+func (jw *jsonlWriter) header(_ []string) error {
+	return nil
+}
+
+// row 按列顺序输出一行JSON对象
+func (jw *jsonlWriter) row(keys []string, values []interface{}) error {
+	jw.w.WriteByte('{')
+	for k, v := range values {
+		if k > 0 {
+			jw.w.WriteByte(',')
+		}
+		key, _ := jw.marshal(keys[k])
+		jw.w.Write(key)
+		jw.w.WriteByte(':')
+		value, err := jw.marshal(v)
+		if err != nil {
+			return err
+		}
+		jw.w.Write(value)
+	}
+	jw.w.WriteString("}\n")
+	return nil
+}
+
+// marshal 不转义HTML字符的JSON编码
+func (jw *jsonlWriter) marshal(v interface{}) ([]byte, error) {
+	jw.buf.Reset()
+	enc := json.NewEncoder(&jw.buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(jw.buf.Bytes(), []byte("\n")), nil
+}
+
+func (jw *jsonlWriter) close() error {
+	return jw.w.Flush()
+}
+
+// xlsxWriter 最小化的xlsx写入，字符串使用内联字符串，工作表内容流式写入zip
+type xlsxWriter struct {
+	zw    *zip.Writer
+	sheet *bufio.Writer
+	rows  int
+}
+
+const xlsxContentTypes = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types"><Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/><Default Extension="xml" ContentType="application/xml"/><Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/><Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/></Types>`
+
+const xlsxRels = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships"><Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/></Relationships>`
+
+const xlsxWorkbook = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships"><sheets><sheet name="%s" sheetId="1" r:id="rId1"/></sheets></workbook>`
+
+const xlsxWorkbookRels = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships"><Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/></Relationships>`
+
+func newXlsxWriter(w io.Writer, sheet string) (*xlsxWriter, error) {
+	zw := zip.NewWriter(w)
+	files := []struct {
+		name    string
+		content string
+	}{
+		{"[Content_Types].xml", xlsxContentTypes},
+		{"_rels/.rels", xlsxRels},
+		{"xl/workbook.xml", fmt.Sprintf(xlsxWorkbook, xmlEscape(sheet))},
+		{"xl/_rels/workbook.xml.rels", xlsxWorkbookRels},
+	}
+	for _, v := range files {
+		f, err := zw.Create(v.name)
+		if err != nil {
+			return nil, err
+		}
+		if _, err = io.WriteString(f, v.content); err != nil {
+			return nil, err
+		}
+	}
+	f, err := zw.Create("xl/worksheets/sheet1.xml")
+	if err != nil {
+		return nil, err
+	}
+	xw := &xlsxWriter{zw: zw, sheet: bufio.NewWriter(f)}
+	xw.sheet.WriteString(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>` + "\n" +
+		`<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>`)
+	return xw, nil
+}
+
+func (xw *xlsxWriter) header(titles []string) error {
+	return xw.row(nil, lo.Map(titles, func(v string, _ int) interface{} {
+		return v
+	}))
+}
+
+func (xw *xlsxWriter) row(_ []string, values []interface{}) error {
+	xw.rows++
+	xw.sheet.WriteString(fmt.Sprintf(`<row r="%d">`, xw.rows))
+	for k, v := range values {
+		ref := xlsxColumn(k) + strconv.Itoa(xw.rows)
+		switch v.(type) {
+		case nil:
+			continue
+		case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, float32, float64:
+			xw.sheet.WriteString(fmt.Sprintf(`<c r="%s"><v>%v</v></c>`, ref, v))
+		default:
+			xw.sheet.WriteString(fmt.Sprintf(`<c r="%s" t="inlineStr"><is><t xml:space="preserve">%s</t></is></c>`, ref, xmlEscape(fmt.Sprint(v))))
+		}
+	}
+	_, err := xw.sheet.WriteString("</row>")
+	return err
+}
+
+func (xw *xlsxWriter) close() error {
+	xw.sheet.WriteString("</sheetData></worksheet>")
+	if err := xw.sheet.Flush(); err != nil {
+		return err
+	}
+	return xw.zw.Close()
+}
+
+// xlsxColumn 列序号转换为列名，0为A
+func xlsxColumn(index int) string {
+	var name []byte
+	for index++; index > 0; index = (index - 1) / 26 {
+		name = append([]byte{byte('A' + (index-1)%26)}, name...)
+	}
+	return string(name)
+}
+
+func xmlEscape(s string) string {
+	var build strings.Builder
+	xml.EscapeText(&build, []byte(s))
+	return build.String()
+}
@@ -0,0 +1,118 @@
+package gorm
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	sqldriver "database/sql/driver"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+func exportGorm(t *testing.T) (*Gorm, *fakeConnector) {
+	created := time.Date(2024, 1, 2, 3, 4, 5, 0, time.Local)
+	return fakeGorm(t, func(query string, args []sqldriver.Value) (*fakeResult, error) {
+		return &fakeResult{columns: []string{"ID", "name", "enable", "createTime"}, rows: [][]sqldriver.Value{
+			{int64(1), []byte("a,\"b\""), int64(1), created},
+			{int64(2), "<c>", int64(2), nil},
+		}}, nil
+	})
+}
+
+func writeExport(t *testing.T, opts ExportOptions) []byte {
+	gm, _ := exportGorm(t)
+	var buf bytes.Buffer
+	count, err := gm.Export(context.Background(), &buf, opts, "SELECT * FROM T_TEST")
+	if err != nil || count != 2 {
+		t.Fatalf("count=%d,err=%v", count, err)
+	}
+	return buf.Bytes()
+}
+
+func TestExport_Format(t *testing.T) {
+	columns := []ExportColumn{
+		{Key: "id", Title: "编号"},
+		{Key: "name", Title: "名称"},
+		{Key: "enable", Title: "启用", Labels: map[string]string{"1": "启用"}},
+		{Key: "createTime", Title: "创建时间", Layout: "2006-01-02"},
+	}
+	data := writeExport(t, ExportOptions{Format: ExportCSV, Columns: columns})
+	expect := "编号,名称,启用,创建时间\n1,\"a,\"\"b\"\"\",启用,2024-01-02\n2,<c>,2,\n"
+	if string(data) != expect {
+		t.Fatalf("csv=%q,expect=%q", data, expect)
+	}
+	data = writeExport(t, ExportOptions{Format: ExportJSONL, Columns: columns})
+	expect = `{"id":1,"name":"a,\"b\"","enable":"启用","createTime":"2024-01-02"}` + "\n" + `{"id":2,"name":"<c>","enable":2,"createTime":null}` + "\n"
+	if string(data) != expect {
+		t.Fatalf("jsonl=%s,expect=%s", data, expect)
+	}
+	columns[2] = ExportColumn{Key: "enable", Title: "启用", Bool: true}
+	data = writeExport(t, ExportOptions{Format: ExportXLSX, Columns: columns})
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatalf("zip.err=%v", err)
+	}
+	var sheet string
+	for _, f := range zr.File {
+		if f.Name == "xl/worksheets/sheet1.xml" {
+			r, _ := f.Open()
+			content, _ := io.ReadAll(r)
+			sheet = string(content)
+		}
+	}
+	for _, v := range []string{`<c r="A2"><v>1</v></c>`, `<c r="C2" t="inlineStr"><is><t xml:space="preserve">是</t></is></c>`, `&lt;c&gt;`, `<row r="3">`} {
+		if !strings.Contains(sheet, v) {
+			t.Fatalf("sheet=%s,expect=%s", sheet, v)
+		}
+	}
+	if _, err = newExportWriter(&bytes.Buffer{}, ExportOptions{Format: "pdf"}); err == nil {
+		t.Fatalf("unknown format must be rejected")
+	}
+}
+
+func TestGorm_ExportPage(t *testing.T) {
+	gm, c := exportGorm(t)
+	var buf bytes.Buffer
+	count, err := gm.ExportPage(context.Background(), &buf, ExportOptions{Format: ExportCSV, NoHeader: true}, "T_TEST", []ConsWrapper{GenCons("type", 1, CompareEqual)}, []QueryOrder{{FieldName: "id", Asc: true}})
+	if err != nil || count != 2 {
+		t.Fatalf("count=%d,err=%v", count, err)
+	}
+	if stmts := c.statements(); len(stmts) != 1 || !strings.Contains(stmts[0], "WHERE 1=1 AND (type = ?) ORDER BY id ASC") {
+		t.Fatalf("stmts=%v", stmts)
+	}
+	if expect := "1,\"a,\"\"b\"\"\",1,2024-01-02 03:04:05\n2,<c>,2,\n"; buf.String() != expect {
+		t.Fatalf("csv=%q,expect=%q", buf.String(), expect)
+	}
+	if _, err = gm.ExportPage(context.Background(), &buf, ExportOptions{Format: ExportCSV}, "T_TEST", []ConsWrapper{GenNot(nil)}, nil); err == nil {
+		t.Fatalf("invalid cons must be rejected")
+	}
+}
+
+func TestGorm_ExportQueryError(t *testing.T) {
+	gm, c := fakeGorm(t, func(query string, args []sqldriver.Value) (*fakeResult, error) {
+		return nil, errors.New("table not exists")
+	})
+	// 查询失败时不输出BOM、表头或xlsx文件头
+	for _, format := range []string{ExportCSV, ExportXLSX} {
+		var buf bytes.Buffer
+		if _, err := gm.Export(context.Background(), &buf, ExportOptions{Format: format, BOM: true}, "SELECT * FROM T_NONE"); err == nil || buf.Len() != 0 {
+			t.Fatalf("format=%s,len=%d,err=%v", format, buf.Len(), err)
+		}
+	}
+	var buf bytes.Buffer
+	if _, err := gm.Export(context.Background(), &buf, ExportOptions{Format: "pdf"}, "SELECT * FROM T_NONE"); err == nil || len(c.statements()) != 2 {
+		t.Fatalf("unsupported format must be rejected before query,stmts=%v,err=%v", c.statements(), err)
+	}
+}
+
+func TestXlsxColumn(t *testing.T) {
+	testdatas := map[int]string{0: "A", 25: "Z", 26: "AA", 51: "AZ", 52: "BA", 701: "ZZ", 702: "AAA"}
+	for k, v := range testdatas {
+		if got := xlsxColumn(k); got != v {
+			t.Fatalf("index=%d,column=%s,expect=%s", k, got, v)
+		}
+	}
+}
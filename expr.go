@@ -0,0 +1,527 @@
+package gorm
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// 过滤表达式语法：
+//
+//	expr    = and { ("or" | "||") and }
+//	and     = unary { ("and" | "&&") unary }
+//	unary   = ("not" | "!") unary | "(" expr ")" | cond
+//	cond    = field op value
+//	        | field "is" ["not"] "null"
+//	        | field ["not"] "in" list
+//	        | field ["not"] "between" value "and" value
+//	        | field ["not"] ("like" | "ilike") string
+//	op      = "=" | "==" | "!=" | "<>" | "<" | "<=" | ">" | ">="
+//	        | "~"(包含) | "!~"(不包含) | "~*"(忽略大小写包含) | "^="(前缀) | "$="(后缀)
+//	value   = number | string | "true" | "false" | date
+//	list    = "[" [value {"," value}] "]" | "(" [value {"," value}] ")"
+//
+// 字符串使用单引号或双引号，日期格式为 2024-01-01 或 2024-01-01T08:00:00，关键字不区分大小写。
+// 例如：(type=1 or type=2) and name~"张" and createTime>=2024-01-01
+
+// ExprError 表达式解析错误，Pos为出错位置(从1开始的字符位置)
+type ExprError struct {
+	Pos int
+	Msg string
+}
+
+func (e *ExprError) Error() string {
+	return fmt.Sprintf("位置%d: %s", e.Pos, e.Msg)
+}
+
+const (
+	tkEOF = iota
+	tkIdent
+	tkString
+	tkNumber
+	tkDate
+	tkOp
+	tkLParen
+	tkRParen
+	tkLBracket
+	tkRBracket
+	tkComma
+)
+
+type exprToken struct {
+	kind  int
+	text  string
+	value interface{}
+	pos   int
+}
+
+type exprLexer struct {
+	src []rune
+	pos int
+}
+
+func (lx *exprLexer) errorf(pos int, format string, args ...interface{}) error {
+	return &ExprError{Pos: pos + 1, Msg: fmt.Sprintf(format, args...)}
+}
+
+func (lx *exprLexer) peekRune(offset int) rune {
+	if lx.pos+offset < len(lx.src) {
+		return lx.src[lx.pos+offset]
+	}
+	return 0
+}
+
+func (lx *exprLexer) next() (exprToken, error) {
+	for lx.pos < len(lx.src) && unicode.IsSpace(lx.src[lx.pos]) {
+		lx.pos++
+	}
+	start := lx.pos
+	if lx.pos >= len(lx.src) {
+		return exprToken{kind: tkEOF, pos: start}, nil
+	}
+	c := lx.src[lx.pos]
+	switch {
+	case c == '(':
+		lx.pos++
+		return exprToken{kind: tkLParen, text: "(", pos: start}, nil
+	case c == ')':
+		lx.pos++
+		return exprToken{kind: tkRParen, text: ")", pos: start}, nil
+	case c == '[':
+		lx.pos++
+		return exprToken{kind: tkLBracket, text: "[", pos: start}, nil
+	case c == ']':
+		lx.pos++
+		return exprToken{kind: tkRBracket, text: "]", pos: start}, nil
+	case c == ',':
+		lx.pos++
+		return exprToken{kind: tkComma, text: ",", pos: start}, nil
+	case c == '"' || c == '\'':
+		return lx.lexString(c)
+	case unicode.IsDigit(c) || (c == '-' && unicode.IsDigit(lx.peekRune(1))):
+		return lx.lexNumber()
+	case unicode.IsLetter(c) || c == '_':
+		for lx.pos < len(lx.src) {
+			r := lx.src[lx.pos]
+			if !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_' && r != '.' {
+				break
+			}
+			lx.pos++
+		}
+		return exprToken{kind: tkIdent, text: string(lx.src[start:lx.pos]), pos: start}, nil
+	}
+	for _, op := range []string{"==", "!=", "<>", "<=", ">=", "!~", "~*", "^=", "$=", "&&", "||", "=", "<", ">", "~", "!"} {
+		if strings.HasPrefix(string(lx.src[lx.pos:]), op) {
+			lx.pos += len([]rune(op))
+			return exprToken{kind: tkOp, text: op, pos: start}, nil
+		}
+	}
+	return exprToken{}, lx.errorf(start, "无法识别的字符%q", c)
+}
+
+func (lx *exprLexer) lexString(quote rune) (exprToken, error) {
+	start := lx.pos
+	lx.pos++
+	var build strings.Builder
+	for lx.pos < len(lx.src) {
+		c := lx.src[lx.pos]
+		lx.pos++
+		switch c {
+		case quote:
+			return exprToken{kind: tkString, text: build.String(), value: build.String(), pos: start}, nil
+		case '\\':
+			if lx.pos >= len(lx.src) {
+				return exprToken{}, lx.errorf(start, "字符串未结束")
+			}
+			e := lx.src[lx.pos]
+			lx.pos++
+			switch e {
+			case 'n':
+				build.WriteRune('\n')
+			case 't':
+				build.WriteRune('\t')
+			default:
+				build.WriteRune(e)
+			}
+		default:
+			build.WriteRune(c)
+		}
+	}
+	return exprToken{}, lx.errorf(start, "字符串未结束")
+}
+
+func (lx *exprLexer) lexNumber() (exprToken, error) {
+	start := lx.pos
+	if lx.src[lx.pos] == '-' {
+		lx.pos++
+	}
+	for lx.pos < len(lx.src) {
+		r := lx.src[lx.pos]
+		if !unicode.IsDigit(r) && r != '.' && r != '-' && r != ':' && r != 'T' {
+			break
+		}
+		lx.pos++
+	}
+	text := string(lx.src[start:lx.pos])
+	if strings.Count(text, "-") >= 2 && !strings.HasPrefix(text, "-") {
+		t, err := parseTime(strings.Replace(text, "T", " ", 1))
+		if err != nil || t == nil {
+			return exprToken{}, lx.errorf(start, "日期格式错误%q", text)
+		}
+		return exprToken{kind: tkDate, text: text, value: *t, pos: start}, nil
+	}
+	if i, err := strconv.ParseInt(text, 10, 64); err == nil {
+		return exprToken{kind: tkNumber, text: text, value: i, pos: start}, nil
+	}
+	if f, err := strconv.ParseFloat(text, 64); err == nil {
+		return exprToken{kind: tkNumber, text: text, value: f, pos: start}, nil
+	}
+	return exprToken{}, lx.errorf(start, "数值格式错误%q", text)
+}
+
+type exprParser struct {
+	lexer *exprLexer
+	token exprToken
+}
+
+// ParseExpr 解析过滤表达式，生成 ConsWrapper 列表，顶层的and条件展开为列表元素
+func ParseExpr(expr string) ([]ConsWrapper, error) {
+	p := &exprParser{lexer: &exprLexer{src: []rune(expr)}}
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+	if p.token.kind == tkEOF {
+		return nil, nil
+	}
+	wp, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.token.kind != tkEOF {
+		return nil, p.errorf("多余的内容%q", p.token.text)
+	}
+	if wp.IsAnd() {
+		return wp.AsAndConstraint().Wrapper, nil
+	}
+	return []ConsWrapper{wp}, nil
+}
+
+func (p *exprParser) advance() error {
+	token, err := p.lexer.next()
+	if err != nil {
+		return err
+	}
+	p.token = token
+	return nil
+}
+
+func (p *exprParser) errorf(format string, args ...interface{}) error {
+	return p.lexer.errorf(p.token.pos, format, args...)
+}
+
+func (p *exprParser) isKeyword(keyword string) bool {
+	return p.token.kind == tkIdent && strings.EqualFold(p.token.text, keyword)
+}
+
+func (p *exprParser) isOp(ops ...string) bool {
+	if p.token.kind != tkOp {
+		return false
+	}
+	for _, v := range ops {
+		if p.token.text == v {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *exprParser) expectKeyword(keyword string) error {
+	if !p.isKeyword(keyword) {
+		return p.errorf("此处应为%s", keyword)
+	}
+	return p.advance()
+}
+
+func (p *exprParser) parseOr() (ConsWrapper, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	var wrapper []ConsWrapper
+	appendOr := func(wp ConsWrapper) {
+		if wp.IsOr() {
+			wrapper = append(wrapper, wp.AsOrConstraint().Wrapper...)
+		} else {
+			wrapper = append(wrapper, wp)
+		}
+	}
+	appendOr(left)
+	count := 1
+	for p.isKeyword("or") || p.isOp("||") {
+		if err = p.advance(); err != nil {
+			return nil, err
+		}
+		right, rErr := p.parseAnd()
+		if rErr != nil {
+			return nil, rErr
+		}
+		appendOr(right)
+		count++
+	}
+	if count == 1 {
+		return left, nil
+	}
+	return OrConstraint{Wrapper: wrapper}, nil
+}
+
+func (p *exprParser) parseAnd() (ConsWrapper, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	var wrapper []ConsWrapper
+	appendAnd := func(wp ConsWrapper) {
+		if wp.IsAnd() {
+			wrapper = append(wrapper, wp.AsAndConstraint().Wrapper...)
+		} else {
+			wrapper = append(wrapper, wp)
+		}
+	}
+	appendAnd(left)
+	count := 1
+	for p.isKeyword("and") || p.isOp("&&") {
+		if err = p.advance(); err != nil {
+			return nil, err
+		}
+		right, rErr := p.parseUnary()
+		if rErr != nil {
+			return nil, rErr
+		}
+		appendAnd(right)
+		count++
+	}
+	if count == 1 {
+		return left, nil
+	}
+	return AndConstraint{Wrapper: wrapper}, nil
+}
+
+func (p *exprParser) parseUnary() (ConsWrapper, error) {
+	if p.isKeyword("not") || p.isOp("!") {
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		wp, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return NotConstraint{Wrapper: wp}, nil
+	}
+	if p.token.kind == tkLParen {
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		wp, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if p.token.kind != tkRParen {
+			return nil, p.errorf("缺少右括号")
+		}
+		return wp, p.advance()
+	}
+	return p.parseCond()
+}
+
+var exprOps = map[string]int{
+	"=":  CompareEqual,
+	"==": CompareEqual,
+	"!=": CompareNotEqual,
+	"<>": CompareNotEqual,
+	"<":  CompareLessThan,
+	"<=": CompareLessThanOrEqual,
+	">":  CompareGreaterThan,
+	">=": CompareGreaterThanOrEqual,
+	"~":  CompareLike,
+	"!~": CompareNotLike,
+	"~*": CompareILike,
+	"^=": CompareStartsWith,
+	"$=": CompareEndsWith,
+}
+
+func (p *exprParser) parseCond() (ConsWrapper, error) {
+	if p.token.kind != tkIdent || isExprKeyword(p.token.text) {
+		return nil, p.errorf("此处应为字段名")
+	}
+	field := p.token.text
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+	if p.token.kind == tkOp {
+		compare, ok := exprOps[p.token.text]
+		if !ok {
+			return nil, p.errorf("不支持的操作符%q", p.token.text)
+		}
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		switch compare {
+		case CompareLike, CompareNotLike, CompareILike, CompareStartsWith, CompareEndsWith:
+			value, err := p.parseString()
+			if err != nil {
+				return nil, err
+			}
+			return GenCons(field, value, compare), nil
+		}
+		value, err := p.parseValue()
+		if err != nil {
+			return nil, err
+		}
+		return GenCons(field, value, compare), nil
+	}
+	if p.isKeyword("is") {
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		compare := CompareIsNull
+		if p.isKeyword("not") {
+			compare = CompareNotNull
+			if err := p.advance(); err != nil {
+				return nil, err
+			}
+		}
+		if err := p.expectKeyword("null"); err != nil {
+			return nil, err
+		}
+		return GenCons(field, nil, compare), nil
+	}
+	not := false
+	if p.isKeyword("not") {
+		not = true
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+	}
+	switch {
+	case p.isKeyword("in"):
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		values, err := p.parseList()
+		if err != nil {
+			return nil, err
+		}
+		if not {
+			return GenCons(field, values, CompareNotIn), nil
+		}
+		return GenCons(field, values, CompareIn), nil
+	case p.isKeyword("between"):
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		begin, err := p.parseValue()
+		if err != nil {
+			return nil, err
+		}
+		if err = p.expectKeyword("and"); err != nil {
+			return nil, err
+		}
+		end, err := p.parseValue()
+		if err != nil {
+			return nil, err
+		}
+		cons := GenBetween(field, begin, end)
+		if not {
+			cons.Compare = CompareNotBetween
+		}
+		return cons, nil
+	case p.isKeyword("like"), p.isKeyword("ilike"):
+		compare := CompareLike
+		if p.isKeyword("ilike") {
+			compare = CompareILike
+		}
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		value, err := p.parseString()
+		if err != nil {
+			return nil, err
+		}
+		if not {
+			if compare == CompareILike {
+				return NotConstraint{Wrapper: GenCons(field, value, compare)}, nil
+			}
+			compare = CompareNotLike
+		}
+		return GenCons(field, value, compare), nil
+	}
+	return nil, p.errorf("%s后缺少操作符", field)
+}
+
+func (p *exprParser) parseString() (string, error) {
+	if p.token.kind != tkString {
+		return "", p.errorf("此处应为字符串")
+	}
+	value := p.token.text
+	return value, p.advance()
+}
+
+func (p *exprParser) parseValue() (interface{}, error) {
+	var value interface{}
+	switch {
+	case p.token.kind == tkString, p.token.kind == tkNumber:
+		value = p.token.value
+	case p.token.kind == tkDate:
+		value = p.token.value.(time.Time)
+	case p.isKeyword("true"):
+		value = true
+	case p.isKeyword("false"):
+		value = false
+	default:
+		return nil, p.errorf("此处应为值")
+	}
+	return value, p.advance()
+}
+
+func (p *exprParser) parseList() ([]interface{}, error) {
+	var end int
+	switch p.token.kind {
+	case tkLBracket:
+		end = tkRBracket
+	case tkLParen:
+		end = tkRParen
+	default:
+		return nil, p.errorf("此处应为列表")
+	}
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+	values := make([]interface{}, 0)
+	for p.token.kind != end {
+		if len(values) > 0 {
+			if p.token.kind != tkComma {
+				return nil, p.errorf("列表元素之间缺少逗号")
+			}
+			if err := p.advance(); err != nil {
+				return nil, err
+			}
+		}
+		value, err := p.parseValue()
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+	}
+	return values, p.advance()
+}
+
+func isExprKeyword(text string) bool {
+	switch strings.ToLower(text) {
+	case "and", "or", "not", "in", "is", "null", "between", "like", "ilike", "true", "false":
+		return true
+	default:
+		return false
+	}
+}
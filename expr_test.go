@@ -0,0 +1,67 @@
+package gorm
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseExpr(t *testing.T) {
+	testdatas := []struct {
+		expr   string
+		expect string
+		params int
+	}{
+		{`(type=1 or type=2) and name~"张" and createTime>=2024-01-01`, " AND (type = ? OR type = ?) AND name LIKE ? ESCAPE '\\\\' AND createTime >= ?", 4},
+		{`code in ['a', 'b'] AND sort not between 1 and 10`, " AND (code IN (?,?) AND sort NOT BETWEEN ? AND ?)", 4},
+		{`not (enable = true || audit != 0) && impl is not null`, " AND NOT ((enable = ? OR audit != ?)) AND impl IS NOT NULL", 2},
+		{`t.name ^= 'ab' or t.name $= "cd" or t.name ~* 'Ef'`, " AND (t.name LIKE ? ESCAPE '\\\\' OR t.name LIKE ? ESCAPE '\\\\' OR UPPER(t.name) LIKE UPPER(?) ESCAPE '\\\\')", 3},
+		{`sort > -1.5 and version not like 'v1' and id not in (1,2,3)`, " AND (sort > ? AND version NOT LIKE ? ESCAPE '\\\\' AND id NOT IN (?,?,?))", 5},
+		{`updateTime < 2024-01-01T08:30:00`, " AND (updateTime < ?)", 1},
+	}
+	for _, item := range testdatas {
+		cons, err := ParseExpr(item.expr)
+		if err != nil {
+			t.Fatalf("%s.err=%v", item.expr, err)
+		}
+		var build strings.Builder
+		params := GenWhereSQL(&build, cons)
+		if build.String() != item.expect || len(params) != item.params {
+			t.Fatalf("%s.sql=%s,params=%v,expect=%s", item.expr, build.String(), params, item.expect)
+		}
+	}
+	cons, _ := ParseExpr(`createTime>=2024-01-01 and type=1 and name='a'`)
+	if v, ok := cons[0].AsConstraint().Value.(time.Time); !ok || v.Format(dateTimeFormatPattern) != "2024-01-01 00:00:00" {
+		t.Fatalf("date value=%v", cons[0].AsConstraint().Value)
+	}
+	if v := cons[1].AsConstraint().Value; v != int64(1) {
+		t.Fatalf("number value=%v", v)
+	}
+}
+
+func TestParseExpr_Error(t *testing.T) {
+	testdatas := []struct {
+		expr string
+		pos  int
+	}{
+		{`name = `, 8},
+		{`(type=1 or type=2`, 18},
+		{`name ~ 1`, 8},
+		{`name = "abc`, 8},
+		{`type = 1 type = 2`, 10},
+		{`name # 1`, 6},
+		{`createTime > 2024-13-01`, 14},
+		{`and = 1`, 1},
+	}
+	for _, item := range testdatas {
+		_, err := ParseExpr(item.expr)
+		var exprErr *ExprError
+		if !errors.As(err, &exprErr) {
+			t.Fatalf("%s must fail, err=%v", item.expr, err)
+		}
+		if exprErr.Pos != item.pos {
+			t.Fatalf("%s.err=%v,expect pos=%d", item.expr, err, item.pos)
+		}
+	}
+}
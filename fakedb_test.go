@@ -0,0 +1,153 @@
+package gorm
+
+import (
+	"context"
+	"database/sql"
+	sqldriver "database/sql/driver"
+	"errors"
+	"gitops.sudytech.cn/guolei/gorm/driver"
+	gmysql "gorm.io/driver/mysql"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+	"io"
+	"sync"
+	"testing"
+)
+
+// fakeResult 测试驱动返回的结果集或影响行数
+type fakeResult struct {
+	columns  []string
+	rows     [][]sqldriver.Value
+	affected int64
+}
+
+// fakeHandler 按SQL及参数返回结果，返回nil时为空结果
+type fakeHandler func(query string, args []sqldriver.Value) (*fakeResult, error)
+
+// fakeConnector 测试用 database/sql 驱动，记录执行的语句及BEGIN、COMMIT、ROLLBACK
+type fakeConnector struct {
+	handler fakeHandler
+	mu      sync.Mutex
+	log     []string
+}
+
+// fakeGorm 使用MySQL方言及测试驱动的连接
+func fakeGorm(t *testing.T, handler fakeHandler) (*Gorm, *fakeConnector) {
+	c := &fakeConnector{handler: handler}
+	db, err := gorm.Open(gmysql.New(gmysql.Config{Conn: sql.OpenDB(c), SkipInitializeWithVersion: true}), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatalf("open.err=%v", err)
+	}
+	return &Gorm{DB: db, Option: &Option{DbType: driver.DBTypeMySQL}}, c
+}
+
+func (c *fakeConnector) record(query string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.log = append(c.log, query)
+}
+
+func (c *fakeConnector) statements() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]string(nil), c.log...)
+}
+
+func (c *fakeConnector) Connect(context.Context) (sqldriver.Conn, error) {
+	return &fakeConn{c: c}, nil
+}
+
+func (c *fakeConnector) Driver() sqldriver.Driver {
+	return fakeDriver{}
+}
+
+type fakeDriver struct{}
+
+func (fakeDriver) Open(string) (sqldriver.Conn, error) {
+	return nil, errors.New("use connector")
+}
+
+type fakeConn struct {
+	c *fakeConnector
+}
+
+func (conn *fakeConn) Prepare(query string) (sqldriver.Stmt, error) {
+	return nil, errors.New("prepare not supported")
+}
+
+func (conn *fakeConn) Close() error {
+	return nil
+}
+
+func (conn *fakeConn) Begin() (sqldriver.Tx, error) {
+	return conn.BeginTx(context.Background(), sqldriver.TxOptions{})
+}
+
+func (conn *fakeConn) BeginTx(_ context.Context, opts sqldriver.TxOptions) (sqldriver.Tx, error) {
+	conn.c.record("BEGIN")
+	return conn, nil
+}
+
+func (conn *fakeConn) Commit() error {
+	conn.c.record("COMMIT")
+	return nil
+}
+
+func (conn *fakeConn) Rollback() error {
+	conn.c.record("ROLLBACK")
+	return nil
+}
+
+func (conn *fakeConn) handle(query string, named []sqldriver.NamedValue) (*fakeResult, error) {
+	conn.c.record(query)
+	if conn.c.handler == nil {
+		return &fakeResult{}, nil
+	}
+	args := make([]sqldriver.Value, len(named))
+	for k, v := range named {
+		args[k] = v.Value
+	}
+	resp, err := conn.c.handler(query, args)
+	if resp == nil {
+		resp = &fakeResult{}
+	}
+	return resp, err
+}
+
+func (conn *fakeConn) ExecContext(_ context.Context, query string, args []sqldriver.NamedValue) (sqldriver.Result, error) {
+	resp, err := conn.handle(query, args)
+	if err != nil {
+		return nil, err
+	}
+	return sqldriver.RowsAffected(resp.affected), nil
+}
+
+func (conn *fakeConn) QueryContext(_ context.Context, query string, args []sqldriver.NamedValue) (sqldriver.Rows, error) {
+	resp, err := conn.handle(query, args)
+	if err != nil {
+		return nil, err
+	}
+	return &fakeRows{result: resp}, nil
+}
+
+type fakeRows struct {
+	result *fakeResult
+	next   int
+}
+
+func (r *fakeRows) Columns() []string {
+	return r.result.columns
+}
+
+func (r *fakeRows) Close() error {
+	return nil
+}
+
+func (r *fakeRows) Next(dest []sqldriver.Value) error {
+	if r.next >= len(r.result.rows) {
+		return io.EOF
+	}
+	copy(dest, r.result.rows[r.next])
+	r.next++
+	return nil
+}
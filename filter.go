@@ -0,0 +1,460 @@
+package gorm
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/samber/lo"
+	"gitops.sudytech.cn/guolei/gorm/sys"
+	"strings"
+)
+
+// 查询条件JSON格式：
+//
+//	条件：{"field":"name","op":"like","value":"张"}
+//	或：{"or":[条件...]}
+//	且：{"and":[条件...]}
+//	非：{"not":条件}
+//	过滤器：{"where":[条件...],"orders":[{"field":"createTime","asc":false}]}
+//
+// like/notLike/ilike/startsWith/endsWith 的value为原始查询值，不含通配符，%、_按普通字符匹配；
+// in/notIn 的value为数组；between/notBetween 的value为两个元素的数组，null表示该端不限；
+// isNull/notNull 不需要value。exists/notExists/raw 不支持JSON格式。
+
+var operators = map[int]string{
+	CompareEqual:              "eq",
+	CompareLessThan:           "lt",
+	CompareLike:               "like",
+	CompareLessThanOrEqual:    "le",
+	CompareGreaterThan:        "gt",
+	CompareGreaterThanOrEqual: "ge",
+	CompareIn:                 "in",
+	CompareNotEqual:           "ne",
+	CompareNotIn:              "notIn",
+	CompareNotLike:            "notLike",
+	CompareIsNull:             "isNull",
+	CompareNotNull:            "notNull",
+	CompareBetween:            "between",
+	CompareNotBetween:         "notBetween",
+	CompareStartsWith:         "startsWith",
+	CompareEndsWith:           "endsWith",
+	CompareILike:              "ilike",
+}
+
+// GetOperator 返回比较类型对应的JSON操作符名称，不支持JSON格式的返回空
+func GetOperator(compare int) string {
+	return operators[compare]
+}
+
+// ParseOperator 解析JSON操作符名称，忽略大小写
+func ParseOperator(op string) (int, bool) {
+	for k, v := range operators {
+		if strings.EqualFold(v, op) {
+			return k, true
+		}
+	}
+	return 0, false
+}
+
+type Filter struct {
+	Where  []ConsWrapper
+	Orders []QueryOrder
+}
+
+type jsonFilter struct {
+	Where  []json.RawMessage `json:"where,omitempty"`
+	Orders []jsonOrder       `json:"orders,omitempty"`
+}
+
+type jsonOrder struct {
+	Field string `json:"field"`
+	Asc   bool   `json:"asc"`
+}
+
+type jsonCons struct {
+	Field string            `json:"field,omitempty"`
+	Op    string            `json:"op,omitempty"`
+	Value json.RawMessage   `json:"value,omitempty"`
+	Or    []json.RawMessage `json:"or,omitempty"`
+	And   []json.RawMessage `json:"and,omitempty"`
+	Not   json.RawMessage   `json:"not,omitempty"`
+}
+
+// ParseFilter 解析并校验JSON格式的过滤器
+func ParseFilter(data []byte) (*Filter, error) {
+	filter := &Filter{}
+	if err := json.Unmarshal(data, filter); err != nil {
+		return nil, err
+	}
+	return filter, nil
+}
+
+func (f Filter) MarshalJSON() ([]byte, error) {
+	resp := jsonFilter{}
+	for _, v := range f.Where {
+		if err := checkJSON(v); err != nil {
+			return nil, err
+		}
+		data, err := marshalCons(v)
+		if err != nil {
+			return nil, err
+		}
+		resp.Where = append(resp.Where, data)
+	}
+	for _, v := range f.Orders {
+		resp.Orders = append(resp.Orders, jsonOrder{Field: v.FieldName, Asc: v.Asc})
+	}
+	return json.Marshal(resp)
+}
+
+func (f *Filter) UnmarshalJSON(data []byte) error {
+	var req jsonFilter
+	if err := json.Unmarshal(data, &req); err != nil {
+		return err
+	}
+	f.Where, f.Orders = nil, nil
+	for k, v := range req.Where {
+		wp, err := UnmarshalCons(v)
+		if err != nil {
+			return fmt.Errorf("where[%d]: %w", k, err)
+		}
+		f.Where = append(f.Where, wp)
+	}
+	for k, v := range req.Orders {
+		if v.Field == "" {
+			return fmt.Errorf("orders[%d]: field为空", k)
+		}
+		f.Orders = append(f.Orders, QueryOrder{FieldName: v.Field, Asc: v.Asc})
+	}
+	return nil
+}
+
+// UnmarshalCons 将JSON格式的条件解析为 ConsWrapper
+func UnmarshalCons(data []byte) (ConsWrapper, error) {
+	var req jsonCons
+	if err := json.Unmarshal(data, &req); err != nil {
+		return nil, err
+	}
+	kinds := 0
+	for _, v := range []bool{req.Field != "" || req.Op != "" || req.Value != nil, req.Or != nil, req.And != nil, req.Not != nil} {
+		kinds += lo.Ternary(v, 1, 0)
+	}
+	if kinds > 1 {
+		return nil, errors.New("field、or、and、not不能同时使用")
+	}
+	switch {
+	case req.Or != nil:
+		wrapper, err := unmarshalConsList("or", req.Or)
+		return OrConstraint{Wrapper: wrapper}, err
+	case req.And != nil:
+		wrapper, err := unmarshalConsList("and", req.And)
+		return AndConstraint{Wrapper: wrapper}, err
+	case req.Not != nil:
+		wp, err := UnmarshalCons(req.Not)
+		if err != nil {
+			return nil, fmt.Errorf("not: %w", err)
+		}
+		return NotConstraint{Wrapper: wp}, nil
+	}
+	if req.Field == "" {
+		return nil, errors.New("field为空")
+	}
+	compare, ok := ParseOperator(req.Op)
+	if !ok {
+		return nil, fmt.Errorf("%s: 不支持的操作符%q", req.Field, req.Op)
+	}
+	value, err := decodeValue(req.Value)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", req.Field, err)
+	}
+	if err = checkValue(compare, value); err != nil {
+		return nil, fmt.Errorf("%s: %w", req.Field, err)
+	}
+	return GenCons(req.Field, value, compare), nil
+}
+
+func unmarshalConsList(name string, req []json.RawMessage) ([]ConsWrapper, error) {
+	if len(req) == 0 {
+		return nil, fmt.Errorf("%s: 条件为空", name)
+	}
+	var wrapper []ConsWrapper
+	for k, v := range req {
+		wp, err := UnmarshalCons(v)
+		if err != nil {
+			return nil, fmt.Errorf("%s[%d]: %w", name, k, err)
+		}
+		wrapper = append(wrapper, wp)
+	}
+	return wrapper, nil
+}
+
+// MarshalJSON 不支持JSON格式的条件(exists/notExists/raw)按文本输出，用于日志，不能再解析
+func (cons Constraint) MarshalJSON() ([]byte, error) {
+	op := GetOperator(cons.Compare)
+	var value interface{}
+	switch cons.Compare {
+	case CompareExists:
+		op, value = "exists", fmt.Sprint(cons.Value)
+	case CompareNotExists:
+		op, value = "notExists", fmt.Sprint(cons.Value)
+	case CompareRaw:
+		op, value = "raw", fmt.Sprint(cons.Value)
+	case CompareIsNull, CompareNotNull:
+	case CompareIn, CompareNotIn, CompareBetween, CompareNotBetween:
+		value = toValues(cons.Value)
+	case CompareLike, CompareNotLike, CompareILike, CompareStartsWith, CompareEndsWith:
+		value = unwrapLike(cons.Compare, cons.Value)
+	default:
+		value = cons.Value
+	}
+	resp := struct {
+		Field string      `json:"field"`
+		Op    string      `json:"op"`
+		Value interface{} `json:"value,omitempty"`
+	}{Field: cons.Name, Op: op, Value: value}
+	return json.Marshal(resp)
+}
+
+func (cons OrConstraint) MarshalJSON() ([]byte, error) {
+	return marshalConsList("or", cons.Wrapper)
+}
+
+func (cons AndConstraint) MarshalJSON() ([]byte, error) {
+	return marshalConsList("and", cons.Wrapper)
+}
+
+func (cons NotConstraint) MarshalJSON() ([]byte, error) {
+	data, err := marshalCons(cons.Wrapper)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(map[string]json.RawMessage{"not": data})
+}
+
+func marshalConsList(name string, wrapper []ConsWrapper) ([]byte, error) {
+	list := make([]json.RawMessage, 0, len(wrapper))
+	for _, v := range wrapper {
+		data, err := marshalCons(v)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, data)
+	}
+	return json.Marshal(map[string][]json.RawMessage{name: list})
+}
+
+func marshalCons(wp ConsWrapper) (json.RawMessage, error) {
+	if wp == nil {
+		return json.RawMessage("null"), nil
+	}
+	return json.Marshal(wp)
+}
+
+// checkJSON 校验条件能否按JSON格式输出后再解析
+func checkJSON(wp ConsWrapper) error {
+	if wp == nil {
+		return errors.New("条件为空")
+	}
+	if wp.IsCons() {
+		cons := wp.AsConstraint()
+		if GetOperator(cons.Compare) == "" {
+			return fmt.Errorf("%s: %s不支持JSON格式", cons.Name, GetSymbol(cons.Compare))
+		}
+		return nil
+	}
+	var wrapper []ConsWrapper
+	if wp.IsOr() && wp.AsOrConstraint() != nil {
+		wrapper = wp.AsOrConstraint().Wrapper
+	} else if wp.IsAnd() && wp.AsAndConstraint() != nil {
+		wrapper = wp.AsAndConstraint().Wrapper
+	} else if wp.IsNot() && wp.AsNotConstraint() != nil {
+		return checkJSON(wp.AsNotConstraint().Wrapper)
+	}
+	if len(wrapper) == 0 {
+		return errors.New("条件组为空")
+	}
+	for _, v := range wrapper {
+		if err := checkJSON(v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (cons *Constraint) UnmarshalJSON(data []byte) error {
+	wp, err := UnmarshalCons(data)
+	if err != nil {
+		return err
+	}
+	if !wp.IsCons() {
+		return errors.New("不是单个条件")
+	}
+	*cons = *wp.AsConstraint()
+	return nil
+}
+
+func (cons *OrConstraint) UnmarshalJSON(data []byte) error {
+	wp, err := UnmarshalCons(data)
+	if err != nil {
+		return err
+	}
+	if !wp.IsOr() {
+		return errors.New("不是or条件")
+	}
+	*cons = *wp.AsOrConstraint()
+	return nil
+}
+
+func (cons *AndConstraint) UnmarshalJSON(data []byte) error {
+	wp, err := UnmarshalCons(data)
+	if err != nil {
+		return err
+	}
+	if !wp.IsAnd() {
+		return errors.New("不是and条件")
+	}
+	*cons = *wp.AsAndConstraint()
+	return nil
+}
+
+func (cons *NotConstraint) UnmarshalJSON(data []byte) error {
+	wp, err := UnmarshalCons(data)
+	if err != nil {
+		return err
+	}
+	if !wp.IsNot() {
+		return errors.New("不是not条件")
+	}
+	*cons = *wp.AsNotConstraint()
+	return nil
+}
+
+// unwrapLike 去除 GenCons 添加的通配符及转义，得到原始查询值
+func unwrapLike(compare int, value interface{}) interface{} {
+	v, ok := value.(string)
+	if !ok {
+		return value
+	}
+	switch compare {
+	case CompareLike, CompareNotLike, CompareILike:
+		if len(v) >= 2 && strings.HasPrefix(v, "%") && strings.HasSuffix(v, "%") {
+			v = v[1 : len(v)-1]
+		}
+	case CompareStartsWith:
+		v = strings.TrimSuffix(v, "%")
+	case CompareEndsWith:
+		v = strings.TrimPrefix(v, "%")
+	}
+	return unescapeLike(v)
+}
+
+func unescapeLike(value string) string {
+	if !strings.Contains(value, "\\") {
+		return value
+	}
+	var build strings.Builder
+	escaped := false
+	for _, r := range value {
+		if r == '\\' && !escaped {
+			escaped = true
+			continue
+		}
+		escaped = false
+		build.WriteRune(r)
+	}
+	return build.String()
+}
+
+// decodeValue 解析JSON值，整数转换为int64，小数转换为float64
+func decodeValue(data json.RawMessage) (interface{}, error) {
+	if len(data) == 0 {
+		return nil, nil
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return nil, err
+	}
+	return toNumber(value), nil
+}
+
+func toNumber(value interface{}) interface{} {
+	switch value.(type) {
+	case json.Number:
+		n := value.(json.Number)
+		if i, err := n.Int64(); err == nil {
+			return i
+		}
+		f, _ := n.Float64()
+		return f
+	case []interface{}:
+		list := value.([]interface{})
+		for k, v := range list {
+			list[k] = toNumber(v)
+		}
+		return list
+	default:
+		return value
+	}
+}
+
+func isScalar(value interface{}) bool {
+	switch value.(type) {
+	case string, bool, int64, float64:
+		return true
+	default:
+		return false
+	}
+}
+
+// checkValue 校验操作符与值类型是否匹配
+func checkValue(compare int, value interface{}) error {
+	switch compare {
+	case CompareIsNull, CompareNotNull:
+		if value != nil {
+			return fmt.Errorf("%s不需要value", GetOperator(compare))
+		}
+	case CompareIn, CompareNotIn:
+		list, ok := value.([]interface{})
+		if !ok {
+			return fmt.Errorf("%s的value必须为数组", GetOperator(compare))
+		}
+		for _, v := range list {
+			if !isScalar(v) {
+				return fmt.Errorf("%s的数组元素必须为字符串、数值或布尔值", GetOperator(compare))
+			}
+		}
+	case CompareBetween, CompareNotBetween:
+		list, ok := value.([]interface{})
+		if !ok || len(list) != 2 {
+			return fmt.Errorf("%s的value必须为两个元素的数组", GetOperator(compare))
+		}
+		for _, v := range list {
+			if v != nil && !isScalar(v) {
+				return fmt.Errorf("%s的数组元素必须为字符串、数值或null", GetOperator(compare))
+			}
+		}
+		if list[0] == nil && list[1] == nil {
+			return fmt.Errorf("%s的value不能全为null", GetOperator(compare))
+		}
+	case CompareLike, CompareNotLike, CompareILike, CompareStartsWith, CompareEndsWith:
+		if _, ok := value.(string); !ok {
+			return fmt.Errorf("%s的value必须为字符串", GetOperator(compare))
+		}
+	default:
+		if !isScalar(value) {
+			return fmt.Errorf("%s的value必须为字符串、数值或布尔值", GetOperator(compare))
+		}
+	}
+	return nil
+}
+
+// FindFilterList 校验过滤器字段白名单后分页查询
+func (gm *Gorm) FindFilterList(model interface{}, pageNo int32, pageSize int32, filter *Filter) ([]Row, int64, sys.IGormErr) {
+	if filter == nil {
+		filter = &Filter{}
+	}
+	return gm.FindSafePageList(model, pageNo, pageSize, filter.Where, filter.Orders)
+}
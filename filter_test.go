@@ -0,0 +1,65 @@
+package gorm
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestParseFilter(t *testing.T) {
+	src := `{"where":[{"or":[{"field":"type","op":"eq","value":1},{"field":"type","op":"eq","value":2}]},{"field":"name","op":"like","value":"张"},{"field":"sort","op":"between","value":[10,null]},{"not":{"field":"code","op":"in","value":["a","b"]}}],"orders":[{"field":"createTime","asc":false}]}`
+	filter, err := ParseFilter([]byte(src))
+	if err != nil {
+		t.Fatalf("parse.err=%v", err)
+	}
+	var build strings.Builder
+	params := GenWhereSQL(&build, filter.Where)
+	GenOrderSQL(&build, filter.Orders)
+	expect := " AND (type = ? OR type = ?) AND name LIKE ? ESCAPE '\\\\' AND sort >= ? AND NOT (code IN (?,?)) ORDER BY createTime DESC"
+	if build.String() != expect {
+		t.Fatalf("sql=%s,expect=%s", build.String(), expect)
+	}
+	if len(params) != 6 || params[0] != int64(1) || params[2] != "%张%" {
+		t.Fatalf("params=%v", params)
+	}
+	data, err := json.Marshal(filter)
+	if err != nil {
+		t.Fatalf("marshal.err=%v", err)
+	}
+	if string(data) != src {
+		t.Fatalf("json=%s,expect=%s", data, src)
+	}
+}
+
+func TestUnmarshalCons_Invalid(t *testing.T) {
+	testdatas := []string{
+		`{"field":"name","op":"regexp","value":"a"}`,
+		`{"field":"name","op":"in","value":"a"}`,
+		`{"field":"name","op":"between","value":[1]}`,
+		`{"field":"name","op":"isNull","value":1}`,
+		`{"field":"name","op":"like","value":1}`,
+		`{"field":"name","op":"eq","value":{"a":1}}`,
+		`{"op":"eq","value":1}`,
+		`{"or":[{"field":"name","op":"eq"}]}`,
+		`{"or":[]}`,
+		`{"and":[]}`,
+		`{"not":{"or":[]}}`,
+		`{"field":"name","op":"eq","value":1,"or":[{"field":"name","op":"eq","value":2}]}`,
+		`{"and":[{"field":"name","op":"eq","value":1}],"not":{"field":"name","op":"eq","value":2}}`,
+	}
+	for _, v := range testdatas {
+		if _, err := UnmarshalCons([]byte(v)); err == nil {
+			t.Fatalf("%s must be rejected", v)
+		}
+	}
+	data, err := json.Marshal([]ConsWrapper{GenRaw("a=?", 1), Not(nil)})
+	if err != nil || string(data) != `[{"field":"a=?","op":"raw","value":"[1]"},{"not":null}]` {
+		t.Fatalf("log json=%s,err=%v", data, err)
+	}
+	if _, err = json.Marshal(Filter{Where: []ConsWrapper{And(Eq("a", 1), GenRaw("1=1"))}}); err == nil {
+		t.Fatalf("raw constraint must not be marshaled as filter")
+	}
+	if _, err = json.Marshal(Filter{Where: []ConsWrapper{Or()}}); err == nil {
+		t.Fatalf("empty group must not be marshaled as filter")
+	}
+}
@@ -3,6 +3,7 @@ module gitops.sudytech.cn/guolei/gorm
 go 1.19
 
 require (
+	github.com/go-sql-driver/mysql v1.6.0
 	github.com/jackc/pgx/v5 v5.3.1
 	github.com/mitchellh/mapstructure v1.5.0
 	github.com/samber/lo v1.38.1
@@ -18,7 +19,6 @@ require (
 
 require (
 	github.com/fsnotify/fsnotify v1.6.0 // indirect
-	github.com/go-sql-driver/mysql v1.6.0 // indirect
 	github.com/golang/snappy v0.0.1 // indirect
 	github.com/hashicorp/hcl v1.0.0 // indirect
 	github.com/jackc/pgpassfile v1.0.0 // indirect
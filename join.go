@@ -0,0 +1,319 @@
+package gorm
+
+import (
+	"errors"
+	"fmt"
+	"github.com/samber/lo"
+	"gitops.sudytech.cn/guolei/gorm/driver"
+	"gitops.sudytech.cn/guolei/gorm/sys"
+	"regexp"
+	"strings"
+)
+
+const (
+	JoinInner = "INNER JOIN"
+	JoinLeft  = "LEFT JOIN"
+)
+
+var regexAlias = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// JoinOn 关联条件，Left=Right，字段使用 alias.column 形式
+type JoinOn struct {
+	Left  string
+	Right string
+}
+
+func On(left string, right string) JoinOn {
+	return JoinOn{Left: left, Right: right}
+}
+
+type joinTable struct {
+	kind  string
+	model interface{}
+	alias string
+	on    []JoinOn
+}
+
+type selectField struct {
+	name string
+	as   string
+}
+
+// SelectBuilder 多表关联查询，表名通过 GetTable 解析，条件和排序字段使用 alias.column 形式并按各模型白名单校验
+//
+//	rows, total, err := gm.From(&User{}, "t").
+//		LeftJoin(&Org{}, "o", On("t.orgId", "o.id")).
+//		Select("t.*").SelectAs("o.name", "orgName").
+//		Where(Eq("o.type", 1), Like("t.name", "张")).
+//		Desc("t.createTime").
+//		Page(1, 20)
+type SelectBuilder struct {
+	gm      *Gorm
+	tables  []joinTable
+	fields  []selectField
+	wrapper []ConsWrapper
+	orders  []QueryOrder
+
+	groups   []string
+	aggs     []Agg
+	having   []ConsWrapper
+	distinct bool
+}
+
+func (gm *Gorm) From(model interface{}, alias string) *SelectBuilder {
+	return &SelectBuilder{gm: gm, tables: []joinTable{{model: model, alias: alias}}}
+}
+
+func (sb *SelectBuilder) Join(model interface{}, alias string, on ...JoinOn) *SelectBuilder {
+	sb.tables = append(sb.tables, joinTable{kind: JoinInner, model: model, alias: alias, on: on})
+	return sb
+}
+
+func (sb *SelectBuilder) LeftJoin(model interface{}, alias string, on ...JoinOn) *SelectBuilder {
+	sb.tables = append(sb.tables, joinTable{kind: JoinLeft, model: model, alias: alias, on: on})
+	return sb
+}
+
+// Select 查询字段，支持 alias.column 及 alias.*，未指定时为主表 alias.*
+func (sb *SelectBuilder) Select(names ...string) *SelectBuilder {
+	for _, v := range names {
+		sb.fields = append(sb.fields, selectField{name: v})
+	}
+	return sb
+}
+
+// SelectAs 查询字段并指定结果列名
+func (sb *SelectBuilder) SelectAs(name string, as string) *SelectBuilder {
+	sb.fields = append(sb.fields, selectField{name: name, as: as})
+	return sb
+}
+
+func (sb *SelectBuilder) Where(cons ...ConsWrapper) *SelectBuilder {
+	sb.wrapper = append(sb.wrapper, cons...)
+	return sb
+}
+
+// Or 添加一组OR条件，该组整体与其它条件AND
+func (sb *SelectBuilder) Or(cons ...ConsWrapper) *SelectBuilder {
+	if len(cons) > 0 {
+		sb.wrapper = append(sb.wrapper, OrConstraint{Wrapper: cons})
+	}
+	return sb
+}
+
+func (sb *SelectBuilder) OrderBy(orders ...QueryOrder) *SelectBuilder {
+	sb.orders = append(sb.orders, orders...)
+	return sb
+}
+
+func (sb *SelectBuilder) Asc(names ...string) *SelectBuilder {
+	for _, v := range names {
+		sb.orders = append(sb.orders, QueryOrder{FieldName: v, Asc: true})
+	}
+	return sb
+}
+
+func (sb *SelectBuilder) Desc(names ...string) *SelectBuilder {
+	for _, v := range names {
+		sb.orders = append(sb.orders, QueryOrder{FieldName: v, Asc: false})
+	}
+	return sb
+}
+
+// joinColumns 多表字段解析，alias.column 按别名所属模型校验，未带别名时在所有表中查找且必须唯一
+type joinColumns struct {
+	aliases []string
+	sets    map[string]*ColumnSet
+}
+
+func (jc *joinColumns) quote(exp driver.Exp, name string) (string, sys.IGormErr) {
+	name = strings.TrimSpace(name)
+	if i := strings.Index(name, "."); i != -1 {
+		alias := name[0:i]
+		cs, ok := jc.sets[strings.ToLower(alias)]
+		if !ok {
+			return "", sys.NewMessage(sys.PropNotAllowCode, fmt.Sprintf("%s的表别名不存在", name))
+		}
+		return cs.quote(exp, name)
+	}
+	var matches []string
+	for _, v := range jc.aliases {
+		if _, ok := jc.sets[strings.ToLower(v)].Column(name); ok {
+			matches = append(matches, v)
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return "", sys.NewMessage(sys.PropNotAllowCode, fmt.Sprintf("%s不在允许的字段范围内", name))
+	case 1:
+		return jc.sets[strings.ToLower(matches[0])].quote(exp, matches[0]+"."+name)
+	default:
+		return "", sys.NewMessage(sys.PropNotAllowCode, fmt.Sprintf("%s存在于多个表(%s)中，需指定表别名", name, strings.Join(matches, ",")))
+	}
+}
+
+func (sb *SelectBuilder) columns() (*joinColumns, sys.IGormErr) {
+	jc := &joinColumns{sets: make(map[string]*ColumnSet)}
+	for _, v := range sb.tables {
+		if !regexAlias.MatchString(v.alias) {
+			return nil, sys.NewMessage(sys.PropNotAllowCode, fmt.Sprintf("表别名%q不合法", v.alias))
+		}
+		if _, ok := jc.sets[strings.ToLower(v.alias)]; ok {
+			return nil, sys.NewMessage(sys.PropNotAllowCode, fmt.Sprintf("表别名%s重复", v.alias))
+		}
+		if _, ok := v.model.(string); ok {
+			return nil, sys.NewMessage(sys.PropNotAllowCode, "关联查询需要使用模型")
+		}
+		jc.aliases = append(jc.aliases, v.alias)
+		jc.sets[strings.ToLower(v.alias)] = ModelColumns(v.model).Alias(v.alias)
+	}
+	return jc, nil
+}
+
+// genFrom 生成 FROM ... JOIN ... WHERE 1=1 部分及条件参数
+func (sb *SelectBuilder) genFrom(build *strings.Builder, exp driver.Exp, jc *joinColumns) ([]interface{}, sys.IGormErr) {
+	for k, v := range sb.tables {
+		if k == 0 {
+			build.WriteString(fmt.Sprintf(" FROM %s %s", sb.gm.GetTable(v.model), exp.Quote(v.alias)))
+			continue
+		}
+		build.WriteString(fmt.Sprintf(" %s %s %s", v.kind, sb.gm.GetTable(v.model), exp.Quote(v.alias)))
+		if len(v.on) == 0 {
+			return nil, sys.NewMessage(sys.PropNotAllowCode, fmt.Sprintf("%s缺少关联条件", v.alias))
+		}
+		for i, on := range v.on {
+			left, err := jc.quote(exp, on.Left)
+			if err != nil {
+				return nil, err
+			}
+			right, err := jc.quote(exp, on.Right)
+			if err != nil {
+				return nil, err
+			}
+			build.WriteString(fmt.Sprintf("%s%s=%s", lo.Ternary(i == 0, " ON ", " AND "), left, right))
+		}
+	}
+	build.WriteString(" WHERE 1=1")
+	cons, err := safeConsList(exp, jc, sb.wrapper)
+	if err != nil {
+		return nil, err
+	}
+	return GenDbWhereSQL(build, exp.DbType, cons), nil
+}
+
+func (sb *SelectBuilder) genFields(build *strings.Builder, exp driver.Exp, jc *joinColumns, exprs map[string]string) sys.IGormErr {
+	fields := sb.fields
+	if len(fields) == 0 {
+		for _, v := range sb.groups {
+			fields = append(fields, selectField{name: v})
+		}
+		if len(fields) == 0 && len(sb.aggs) == 0 {
+			fields = []selectField{{name: sb.tables[0].alias + ".*"}}
+		}
+	}
+	build.WriteString(lo.Ternary(sb.distinct, "SELECT DISTINCT ", "SELECT "))
+	for k, v := range fields {
+		build.WriteString(lo.Ternary(k == 0, "", ","))
+		if strings.HasSuffix(v.name, ".*") {
+			alias := strings.TrimSuffix(v.name, ".*")
+			if _, ok := jc.sets[strings.ToLower(alias)]; !ok || v.as != "" {
+				return sys.NewMessage(sys.PropNotAllowCode, fmt.Sprintf("%s不在允许的字段范围内", v.name))
+			}
+			build.WriteString(exp.Quote(alias) + ".*")
+			continue
+		}
+		column, err := jc.quote(exp, v.name)
+		if err != nil {
+			return err
+		}
+		build.WriteString(column)
+		if v.as != "" {
+			if !regexAlias.MatchString(v.as) {
+				return sys.NewMessage(sys.PropNotAllowCode, fmt.Sprintf("列名%q不合法", v.as))
+			}
+			build.WriteString(" AS " + exp.Quote(v.as))
+		}
+	}
+	for k, v := range sb.aggs {
+		build.WriteString(lo.Ternary(k == 0 && len(fields) == 0, "", ","))
+		build.WriteString(exprs[strings.ToLower(v.As)] + " AS " + exp.Quote(v.As))
+	}
+	return nil
+}
+
+// SQL 生成记录查询和总数查询SQL，两者共用参数，分组、去重或聚合时总数SQL包装为子查询
+func (sb *SelectBuilder) SQL() (string, string, []interface{}, sys.IGormErr) {
+	exp := driver.Exp{DbType: sb.gm.Option.DbType, Schema: sb.gm.Option.Schema}
+	jc, err := sb.columns()
+	if err != nil {
+		return "", "", nil, err
+	}
+	exprs, err := sb.genAggs(exp, jc)
+	if err != nil {
+		return "", "", nil, err
+	}
+	ac := &aggColumns{jc: jc, exprs: exprs}
+	var from strings.Builder
+	params, err := sb.genFrom(&from, exp, jc)
+	if err != nil {
+		return "", "", nil, err
+	}
+	having, err := sb.genGroup(&from, exp, ac)
+	if err != nil {
+		return "", "", nil, err
+	}
+	params = append(params, having...)
+	orders, err := safeOrders(exp, ac, sb.orders)
+	if err != nil {
+		return "", "", nil, err
+	}
+	var rows strings.Builder
+	if err = sb.genFields(&rows, exp, jc, exprs); err != nil {
+		return "", "", nil, err
+	}
+	rows.WriteString(from.String())
+	count := "SELECT COUNT(1)" + from.String()
+	if sb.grouped() || len(sb.aggs) > 0 {
+		count = fmt.Sprintf("SELECT COUNT(*) FROM (%s) c", rows.String())
+	}
+	GenOrderSQL(&rows, orders)
+	return rows.String(), count, params, nil
+}
+
+func (sb *SelectBuilder) sql() (string, string, []interface{}, error) {
+	rows, count, params, err := sb.SQL()
+	if err != nil {
+		return "", "", nil, errors.New(err.GetMessage())
+	}
+	return rows, count, params, nil
+}
+
+// List 查询全部记录
+func (sb *SelectBuilder) List() ([]Row, error) {
+	rows, _, params, err := sb.sql()
+	if err != nil {
+		return nil, err
+	}
+	return sb.gm.QueryRows(0, 0, rows, params...)
+}
+
+func (sb *SelectBuilder) Count() (int64, error) {
+	_, count, params, err := sb.sql()
+	if err != nil {
+		return 0, err
+	}
+	return sb.gm.QueryTotal(count, params...)
+}
+
+// Page 分页查询记录及总数，pageNo为0时不查询总数，pageSize为0时不查询记录，同 FindPageList
+func (sb *SelectBuilder) Page(pageNo int32, pageSize int32) ([]Row, int64, error) {
+	rows, count, params, err := sb.sql()
+	if err != nil {
+		return nil, 0, err
+	}
+	return findPageList(pageNo, pageSize, func() (int64, error) {
+		return sb.gm.QueryTotal(count, params...)
+	}, func() ([]Row, error) {
+		return sb.gm.QueryRows(pageNo, pageSize, rows, params...)
+	})
+}
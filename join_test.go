@@ -0,0 +1,58 @@
+package gorm
+
+import (
+	"gitops.sudytech.cn/guolei/gorm/driver"
+	"gitops.sudytech.cn/guolei/gorm/testdata"
+	"testing"
+)
+
+func TestSelectBuilder_SQL(t *testing.T) {
+	gm := &Gorm{Option: &Option{DbType: driver.DBTypeUxDB}}
+	rows, count, params, err := gm.From(&testdata.Algorithm{}, "t").
+		LeftJoin(&testdata.Algorithm{}, "p", On("t.impl", "p.code")).
+		Select("t.*").SelectAs("p.name", "implName").
+		Where(Eq("t.type", 1), Like("p.name", "张")).
+		Desc("t.createTime").
+		SQL()
+	if err != nil {
+		t.Fatalf("sql.err=%v", err.GetMessage())
+	}
+	from := ` FROM "T_TEST_ALGORITHM" "t" LEFT JOIN "T_TEST_ALGORITHM" "p" ON "t"."impl"="p"."code" WHERE 1=1 AND ("t"."type" = ? AND "p"."name" LIKE ? ESCAPE '\')`
+	if expect := `SELECT "t".*,"p"."name" AS "implName"` + from + ` ORDER BY "t"."createTime" DESC`; rows != expect {
+		t.Fatalf("rows=%s,expect=%s", rows, expect)
+	}
+	if expect := `SELECT COUNT(1)` + from; count != expect {
+		t.Fatalf("count=%s,expect=%s", count, expect)
+	}
+	if len(params) != 2 || params[1] != "%张%" {
+		t.Fatalf("params=%v", params)
+	}
+
+	gm = &Gorm{Option: &Option{DbType: driver.DBTypeMySQL}}
+	rows, _, _, err = gm.From(&testdata.Algorithm{}, "t").Select("name").Where(Eq("sort", 1)).SQL()
+	if err != nil {
+		t.Fatalf("sql.err=%v", err.GetMessage())
+	}
+	if expect := "SELECT `t`.`name` FROM T_TEST_ALGORITHM `t` WHERE 1=1 AND (`t`.`sort` = ?)"; rows != expect {
+		t.Fatalf("rows=%s,expect=%s", rows, expect)
+	}
+}
+
+func TestSelectBuilder_Invalid(t *testing.T) {
+	gm := &Gorm{Option: &Option{DbType: driver.DBTypeMySQL}}
+	testdatas := []*SelectBuilder{
+		gm.From(&testdata.Algorithm{}, "t").Join(&testdata.Algorithm{}, "p", On("t.impl", "p.code")).Where(Eq("name", 1)),
+		gm.From(&testdata.Algorithm{}, "t").Join(&testdata.Algorithm{}, "p"),
+		gm.From(&testdata.Algorithm{}, "t").Join(&testdata.Algorithm{}, "t", On("t.impl", "t.code")),
+		gm.From(&testdata.Algorithm{}, "t;drop").Where(Eq("name", 1)),
+		gm.From(&testdata.Algorithm{}, "t").Where(Eq("x.name", 1)),
+		gm.From(&testdata.Algorithm{}, "t").SelectAs("name", "a b"),
+		gm.From(&testdata.Algorithm{}, "t").Desc("password"),
+		gm.From(testdata.TableAlgorithm, "t"),
+	}
+	for k, v := range testdatas {
+		if _, _, _, err := v.SQL(); err == nil {
+			t.Fatalf("%d must be rejected", k)
+		}
+	}
+}
@@ -0,0 +1,284 @@
+package gorm
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"gitops.sudytech.cn/guolei/gorm/driver"
+	"go.uber.org/zap"
+	"strings"
+	"time"
+)
+
+// OutboxTable 事件发件箱表名，通过 OutboxDDL 或 CreateOutbox 创建
+const OutboxTable = "T_GORM_OUTBOX"
+
+const (
+	OutboxPending = 0 // 待投递
+	OutboxSent    = 1 // 已投递
+	OutboxFailed  = 2 // 超过最多投递次数，不再投递
+)
+
+// OutboxEvent 发件箱事件，与业务SQL在同一事务中写入，提交后由 Dispatcher 投递；
+// 投递至少一次，消费方需按Id或业务键幂等
+type OutboxEvent struct {
+	Id         int64
+	Topic      string
+	Key        string // 顺序键，相同Key的事件按写入顺序投递，为空时不保证顺序
+	Payload    string
+	Attempts   int // 已投递次数，含本次
+	CreateTime time.Time
+}
+
+// NewOutboxEvent 创建事件，payload为string、[]byte时原样写入，其它类型序列化为JSON
+func NewOutboxEvent(topic string, key string, payload interface{}) (OutboxEvent, error) {
+	event := OutboxEvent{Topic: topic, Key: key}
+	switch v := payload.(type) {
+	case string:
+		event.Payload = v
+	case []byte:
+		event.Payload = string(v)
+	default:
+		var buf bytes.Buffer
+		enc := json.NewEncoder(&buf)
+		enc.SetEscapeHTML(false)
+		if err := enc.Encode(v); err != nil {
+			return event, err
+		}
+		event.Payload = strings.TrimSuffix(buf.String(), "\n")
+	}
+	return event, nil
+}
+
+// OutboxDDL 发件箱建表及索引语句，时间字段为毫秒时间戳
+func OutboxDDL(dbType int, schema string) []string {
+	exp := driver.Exp{DbType: dbType, Schema: schema}
+	table := exp.TableName(OutboxTable)
+	q := exp.Quote
+	var id, text string
+	switch dbType {
+	case driver.DBTypeUxDB, driver.DBTypeVbDB:
+		id, text = "BIGSERIAL PRIMARY KEY", "TEXT"
+	case driver.DBTypeDmDB:
+		id, text = "BIGINT IDENTITY(1,1) PRIMARY KEY", "TEXT"
+	default:
+		id, text = "BIGINT NOT NULL AUTO_INCREMENT PRIMARY KEY", "LONGTEXT"
+	}
+	return []string{
+		fmt.Sprintf("CREATE TABLE %s (%s %s,%s VARCHAR(200) NOT NULL,%s VARCHAR(200) DEFAULT '' NOT NULL,%s %s,"+
+			"%s SMALLINT DEFAULT 0 NOT NULL,%s INT DEFAULT 0 NOT NULL,%s BIGINT NOT NULL,%s BIGINT NOT NULL,%s BIGINT,%s VARCHAR(1000))",
+			table, q("id"), id, q("topic"), q("msgKey"), q("payload"), text,
+			q("status"), q("attempts"), q("nextTime"), q("createTime"), q("sentTime"), q("lastError")),
+		fmt.Sprintf("CREATE INDEX %s ON %s (%s,%s)", q("IDX_GORM_OUTBOX_STATUS"), table, q("status"), q("nextTime")),
+	}
+}
+
+// CreateOutbox 发件箱表不存在时创建
+func (gm *Gorm) CreateOutbox() error {
+	if gm.DB.Migrator().HasTable(OutboxTable) {
+		return nil
+	}
+	for _, v := range OutboxDDL(gm.Option.DbType, gm.Option.Schema) {
+		if err := gm.DB.Exec(v).Error; err != nil {
+			zap.S().Errorf("create outbox:sql=%s,err=%v", v, err)
+			return err
+		}
+	}
+	return nil
+}
+
+// GetOutboxSQL 生成事件写入SQL，与业务SQL一起通过 TranSQL、TransactionSQL 执行
+//
+//	event, _ := NewOutboxEvent("user.created", userId, user)
+//	err := gm.TranSQL(append(sqls, gm.GetOutboxSQL(event)...))
+func (opt *Option) GetOutboxSQL(events ...OutboxEvent) []TranSQL {
+	var resp []TranSQL
+	now := time.Now()
+	for _, v := range events {
+		createTime := v.CreateTime
+		if createTime.IsZero() {
+			createTime = now
+		}
+		resp = append(resp, TranSQL{
+			SQL:    fmt.Sprintf("INSERT INTO %s(topic,msgKey,payload,status,attempts,nextTime,createTime) VALUES(?,?,?,?,?,?,?)", opt.GetTable(OutboxTable)),
+			Params: []interface{}{v.Topic, v.Key, v.Payload, OutboxPending, 0, createTime.UnixMilli(), createTime.UnixMilli()},
+		})
+	}
+	return resp
+}
+
+func (gm *Gorm) GetOutboxSQL(events ...OutboxEvent) []TranSQL {
+	return gm.Option.GetOutboxSQL(events...)
+}
+
+// SaveOutbox 写入事件，在 Transaction 的 WithContext(ctx) 或 WithTx(tx) 连接上调用时与业务SQL同事务
+func (gm *Gorm) SaveOutbox(events ...OutboxEvent) error {
+	for _, v := range gm.GetOutboxSQL(events...) {
+		if err := gm.ExecSQL(v.SQL, v.Params...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Publisher 事件投递，返回错误时按 DispatcherOptions.Retry 延迟重新投递
+type Publisher interface {
+	Publish(ctx context.Context, event OutboxEvent) error
+}
+
+type PublisherFunc func(ctx context.Context, event OutboxEvent) error
+
+func (f PublisherFunc) Publish(ctx context.Context, event OutboxEvent) error {
+	return f(ctx, event)
+}
+
+// DispatcherOptions 投递选项
+type DispatcherOptions struct {
+	BatchSize int           // 每批读取的事件数，默认100
+	Interval  time.Duration // 没有待投递事件时的轮询间隔，默认1s
+	Lease     time.Duration // 投递占用时间，超时未完成(如进程退出)的事件重新投递，默认30s
+	// Retry 投递失败后的延迟策略，使用 BaseDelay、MaxDelay、Jitter；MaxAttempts为最多投递次数，超过后标记为 OutboxFailed，0为不限制
+	Retry RetryPolicy
+}
+
+// Dispatcher 发件箱投递，按Id顺序逐条投递；某个Key的事件未投递成功时，该Key之后的事件等待其成功或失败后再投递。
+// 多个实例可同时运行，事件通过更新nextTime占用，不会被重复投递(超过Lease除外)
+type Dispatcher struct {
+	gm        *Gorm
+	publisher Publisher
+	opts      DispatcherOptions
+}
+
+func (gm *Gorm) NewDispatcher(publisher Publisher, opts DispatcherOptions) *Dispatcher {
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = 100
+	}
+	if opts.Interval <= 0 {
+		opts.Interval = time.Second
+	}
+	if opts.Lease <= 0 {
+		opts.Lease = 30 * time.Second
+	}
+	return &Dispatcher{gm: gm, publisher: publisher, opts: opts}
+}
+
+// Run 循环投递直到ctx结束，一批全部投递成功时立即读取下一批
+func (d *Dispatcher) Run(ctx context.Context) error {
+	for {
+		n, err := d.Dispatch(ctx)
+		if err != nil {
+			zap.S().Errorf("outbox dispatch:err=%v", err)
+		}
+		wait := d.opts.Interval
+		if err == nil && n >= d.opts.BatchSize {
+			wait = 0
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(wait):
+		}
+	}
+}
+
+// Dispatch 投递一批到期事件，返回投递成功的事件数；同Key存在延迟重试或投递中的更早事件时，该事件不在读取范围内
+func (d *Dispatcher) Dispatch(ctx context.Context) (int, error) {
+	gm := d.gm.WithContext(ctx)
+	exp := driver.Exp{DbType: gm.Option.DbType, Schema: gm.Option.Schema}
+	table, q := exp.TableName(OutboxTable), exp.Quote
+	now := time.Now().UnixMilli()
+	var due []map[string]interface{}
+	if err := gm.DB.Raw(fmt.Sprintf("SELECT o.%s,o.%s,o.%s,o.%s,o.%s,o.%s FROM %s o WHERE o.%s=? AND o.%s<=? AND NOT EXISTS "+
+		"(SELECT 1 FROM %s b WHERE b.%s=o.%s AND b.%s<o.%s AND b.%s=? AND b.%s>? AND b.%s<>'') ORDER BY o.%s LIMIT %d",
+		q("id"), q("topic"), q("msgKey"), q("payload"), q("attempts"), q("createTime"), table, q("status"), q("nextTime"),
+		table, q("msgKey"), q("msgKey"), q("id"), q("id"), q("status"), q("nextTime"), q("msgKey"), q("id"), d.opts.BatchSize),
+		OutboxPending, now, OutboxPending, now).Scan(&due).Error; err != nil {
+		return 0, err
+	}
+	// 本批中投递失败或未占用到的事件阻塞同Key的后续事件
+	blocked := make(map[string]bool)
+	delivered := 0
+	for _, v := range Rows(due) {
+		if err := ctx.Err(); err != nil {
+			return delivered, err
+		}
+		event := OutboxEvent{
+			Id:         v.GetInt64("id"),
+			Topic:      v.GetString("topic"),
+			Key:        v.GetString("msgKey"),
+			Payload:    v.GetString("payload"),
+			Attempts:   v.GetInt("attempts") + 1,
+			CreateTime: time.UnixMilli(v.GetInt64("createTime")),
+		}
+		if event.Key != "" && blocked[event.Key] {
+			continue
+		}
+		sent, block, err := d.deliver(ctx, gm, exp, event)
+		if err != nil {
+			return delivered, err
+		}
+		if sent {
+			delivered++
+		}
+		if block && event.Key != "" {
+			blocked[event.Key] = true
+		}
+	}
+	return delivered, nil
+}
+
+// deliver 占用并投递事件，返回是否投递成功及是否阻塞同Key的后续事件，未占用到或等待重试时阻塞，标记为失败时不阻塞
+func (d *Dispatcher) deliver(ctx context.Context, gm *Gorm, exp driver.Exp, event OutboxEvent) (bool, bool, error) {
+	table, q := exp.TableName(OutboxTable), exp.Quote
+	now := time.Now()
+	claim := gm.DB.Exec(fmt.Sprintf("UPDATE %s SET %s=%s+1,%s=? WHERE %s=? AND %s=? AND %s<=?",
+		table, q("attempts"), q("attempts"), q("nextTime"), q("id"), q("status"), q("nextTime")),
+		now.Add(d.opts.Lease).UnixMilli(), event.Id, OutboxPending, now.UnixMilli())
+	if claim.Error != nil {
+		return false, true, claim.Error
+	}
+	if claim.RowsAffected == 0 {
+		return false, true, nil
+	}
+	pErr := d.publish(ctx, event)
+	if pErr == nil {
+		return true, false, gm.DB.Exec(fmt.Sprintf("UPDATE %s SET %s=?,%s=?,%s=NULL WHERE %s=?",
+			table, q("status"), q("sentTime"), q("lastError"), q("id")),
+			OutboxSent, time.Now().UnixMilli(), event.Id).Error
+	}
+	status := OutboxPending
+	if max := d.opts.Retry.MaxAttempts; max > 0 && event.Attempts >= max {
+		status = OutboxFailed
+	}
+	zap.S().Warnf("outbox publish:id=%d,topic=%s,key=%s,attempts=%d,err=%v", event.Id, event.Topic, event.Key, event.Attempts, pErr)
+	msg := pErr.Error()
+	if len(msg) > 1000 {
+		msg = msg[0:1000]
+	}
+	return false, status == OutboxPending, gm.DB.Exec(fmt.Sprintf("UPDATE %s SET %s=?,%s=?,%s=? WHERE %s=?",
+		table, q("status"), q("nextTime"), q("lastError"), q("id")),
+		status, time.Now().Add(d.opts.Retry.delay(event.Attempts)).UnixMilli(), msg, event.Id).Error
+}
+
+// publish 投递事件，panic按投递失败处理
+func (d *Dispatcher) publish(ctx context.Context, event OutboxEvent) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("publish panic:%v", r)
+		}
+	}()
+	if d.publisher == nil {
+		return errors.New("publisher is nil")
+	}
+	return d.publisher.Publish(ctx, event)
+}
+
+// PurgeOutbox 删除before之前创建的已投递事件，返回删除数
+func (gm *Gorm) PurgeOutbox(before time.Time) (int64, error) {
+	exp := driver.Exp{DbType: gm.Option.DbType, Schema: gm.Option.Schema}
+	resp := gm.DB.Exec(fmt.Sprintf("DELETE FROM %s WHERE %s=? AND %s<?",
+		exp.TableName(OutboxTable), exp.Quote("status"), exp.Quote("createTime")), OutboxSent, before.UnixMilli())
+	return resp.RowsAffected, gm.toError(resp)
+}
@@ -0,0 +1,161 @@
+package gorm
+
+import (
+	"context"
+	sqldriver "database/sql/driver"
+	"errors"
+	"fmt"
+	"github.com/samber/lo"
+	"gitops.sudytech.cn/guolei/gorm/driver"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestOutboxDDL(t *testing.T) {
+	ddl := OutboxDDL(driver.DBTypeUxDB, "app")
+	expect := `CREATE TABLE "app"."T_GORM_OUTBOX" ("id" BIGSERIAL PRIMARY KEY,"topic" VARCHAR(200) NOT NULL,"msgKey" VARCHAR(200) DEFAULT '' NOT NULL,"payload" TEXT,` +
+		`"status" SMALLINT DEFAULT 0 NOT NULL,"attempts" INT DEFAULT 0 NOT NULL,"nextTime" BIGINT NOT NULL,"createTime" BIGINT NOT NULL,"sentTime" BIGINT,"lastError" VARCHAR(1000))`
+	if len(ddl) != 2 || ddl[0] != expect {
+		t.Fatalf("ddl=%v", ddl)
+	}
+	if ddl[1] != `CREATE INDEX "IDX_GORM_OUTBOX_STATUS" ON "app"."T_GORM_OUTBOX" ("status","nextTime")` {
+		t.Fatalf("index=%s", ddl[1])
+	}
+	if ddl = OutboxDDL(driver.DBTypeDmDB, ""); !strings.HasPrefix(ddl[0], `CREATE TABLE "T_GORM_OUTBOX" ("id" BIGINT IDENTITY(1,1) PRIMARY KEY,`) {
+		t.Fatalf("dm ddl=%s", ddl[0])
+	}
+	if ddl = OutboxDDL(-1, ""); !strings.HasPrefix(ddl[0], "CREATE TABLE T_GORM_OUTBOX (`id` BIGINT NOT NULL AUTO_INCREMENT PRIMARY KEY,") {
+		t.Fatalf("mysql ddl=%s", ddl[0])
+	}
+}
+
+func TestGetOutboxSQL(t *testing.T) {
+	event, err := NewOutboxEvent("user.created", "u1", map[string]interface{}{"name": "<张三>"})
+	if err != nil || event.Payload != `{"name":"<张三>"}` {
+		t.Fatalf("payload=%s,err=%v", event.Payload, err)
+	}
+	event.CreateTime = time.UnixMilli(1700000000000)
+	opt := &Option{DbType: driver.DBTypeUxDB}
+	sqls := opt.GetOutboxSQL(event)
+	if len(sqls) != 1 || len(sqls[0].Params) != 7 || sqls[0].Params[5] != int64(1700000000000) {
+		t.Fatalf("sqls=%v", sqls)
+	}
+	exp := driver.Exp{DbType: driver.DBTypeUxDB}
+	nSQL := exp.ExecSQL(sqls[0].SQL)
+	expect := `INSERT INTO "T_GORM_OUTBOX"("topic","msgKey","payload","status","attempts","nextTime","createTime") VALUES(?,?,?,?,?,?,?)`
+	if nSQL != expect {
+		t.Fatalf("sql=%s,expect=%s", nSQL, expect)
+	}
+}
+
+// memOutbox 按 Dispatcher 生成的SQL模拟发件箱表
+type memOutbox struct {
+	mu   sync.Mutex
+	rows []*memEvent
+}
+
+type memEvent struct {
+	id, nextTime     int64
+	key              string
+	status, attempts int
+}
+
+func (m *memOutbox) find(id int64) *memEvent {
+	for _, v := range m.rows {
+		if v.id == id {
+			return v
+		}
+	}
+	return nil
+}
+
+func (m *memOutbox) handle(query string, args []sqldriver.Value) (*fakeResult, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	switch {
+	case strings.HasPrefix(query, "SELECT o.`id`"):
+		var limit int
+		fmt.Sscanf(query[strings.LastIndex(query, "LIMIT ")+6:], "%d", &limit)
+		now := args[1].(int64)
+		resp := &fakeResult{columns: []string{"id", "topic", "msgKey", "payload", "attempts", "createTime"}}
+		for _, v := range m.rows {
+			if v.status != OutboxPending || v.nextTime > now || len(resp.rows) >= limit {
+				continue
+			}
+			blocked := lo.ContainsBy(m.rows, func(b *memEvent) bool {
+				return b.key == v.key && b.id < v.id && b.status == OutboxPending && b.nextTime > now && b.key != ""
+			})
+			if !blocked {
+				resp.rows = append(resp.rows, []sqldriver.Value{v.id, "t", v.key, "p", int64(v.attempts), int64(0)})
+			}
+		}
+		return resp, nil
+	case strings.Contains(query, "SET `attempts`=`attempts`+1"):
+		if v := m.find(args[1].(int64)); v != nil && v.status == OutboxPending && v.nextTime <= args[3].(int64) {
+			v.attempts++
+			v.nextTime = args[0].(int64)
+			return &fakeResult{affected: 1}, nil
+		}
+		return &fakeResult{}, nil
+	case strings.Contains(query, "SET `status`=?,`sentTime`=?"):
+		m.find(args[2].(int64)).status = OutboxSent
+	case strings.Contains(query, "SET `status`=?,`nextTime`=?,`lastError`=?"):
+		v := m.find(args[3].(int64))
+		v.status, v.nextTime = int(args[0].(int64)), args[1].(int64)
+	default:
+		return nil, fmt.Errorf("unexpected sql:%s", query)
+	}
+	return &fakeResult{affected: 1}, nil
+}
+
+func TestDispatcher_Dispatch(t *testing.T) {
+	future := time.Now().Add(time.Hour).UnixMilli()
+	store := &memOutbox{rows: []*memEvent{
+		{id: 1, key: "a"}, {id: 2, key: "a"}, {id: 3, key: "b"}, {id: 4},
+		{id: 5, key: "c", nextTime: future}, {id: 6, key: "c"}, // 5投递中(未超过Lease)，阻塞6
+		{id: 7, key: "d", attempts: 1}, {id: 8, key: "d"},
+		{id: 9, key: "e"},
+	}}
+	gm, _ := fakeGorm(t, store.handle)
+	var published []int64
+	failed := map[int64]int{1: 1, 7: 10}
+	publisher := PublisherFunc(func(ctx context.Context, event OutboxEvent) error {
+		published = append(published, event.Id)
+		if failed[event.Id] > 0 {
+			failed[event.Id]--
+			return errors.New("publish failed")
+		}
+		return nil
+	})
+	d := gm.NewDispatcher(publisher, DispatcherOptions{BatchSize: 10, Retry: RetryPolicy{MaxAttempts: 2, BaseDelay: time.Hour, MaxDelay: time.Hour}})
+	n, err := d.Dispatch(context.Background())
+	// 1失败阻塞2；7第二次失败标记为失败，不再阻塞8
+	if err != nil || n != 4 || fmt.Sprint(published) != "[1 3 4 7 8 9]" {
+		t.Fatalf("n=%d,published=%v,err=%v", n, published, err)
+	}
+	if v := store.find(7); v.status != OutboxFailed || v.attempts != 2 {
+		t.Fatalf("event 7=%+v", v)
+	}
+	if v := store.find(1); v.status != OutboxPending || v.attempts != 1 || v.nextTime < future-time.Minute.Milliseconds() {
+		t.Fatalf("event 1=%+v", v)
+	}
+	// 1、5延迟中时同Key的2、6不在读取范围内，其它Key不受BatchSize影响
+	store.rows = append(store.rows, &memEvent{id: 10, key: "a"}, &memEvent{id: 11, key: "f"})
+	published = nil
+	if n, err = gm.NewDispatcher(publisher, DispatcherOptions{BatchSize: 1}).Dispatch(context.Background()); err != nil || n != 1 || fmt.Sprint(published) != "[11]" {
+		t.Fatalf("n=%d,published=%v,err=%v", n, published, err)
+	}
+	store.find(1).nextTime, store.find(5).nextTime = 0, 0
+	published = nil
+	if n, err = d.Dispatch(context.Background()); err != nil || n != 5 || fmt.Sprint(published) != "[1 2 5 6 10]" {
+		t.Fatalf("n=%d,published=%v,err=%v", n, published, err)
+	}
+	if v := store.find(5); v.status != OutboxSent || v.attempts != 1 {
+		t.Fatalf("event 5=%+v", v)
+	}
+	if n, err = d.Dispatch(context.Background()); err != nil || n != 0 {
+		t.Fatalf("n=%d,err=%v", n, err)
+	}
+}
@@ -0,0 +1,281 @@
+package gorm
+
+import (
+	"errors"
+	"fmt"
+	"github.com/spf13/cast"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"sync"
+)
+
+// Page 分页结果，未查询总数时Total为-1、TotalPages为0
+type Page struct {
+	Items      []Row
+	Total      int64
+	PageNo     int32
+	PageSize   int32
+	TotalPages int32
+	HasNext    bool
+	Estimated  bool // 总数为统计信息估算值
+	Capped     bool // 总数超过 CountOptions.Cap，Total为上限
+}
+
+// PageOptions 分页选项
+type PageOptions struct {
+	SkipCount   bool  // 不查询总数，多查询一条记录判断是否有下一页
+	CountOnly   bool  // 只查询总数
+	MaxPageSize int32 // 每页记录数上限，超过时按上限查询，0为不限制
+	Count       CountOptions
+}
+
+// NewPage 根据记录和总数生成分页结果
+func NewPage(items []Row, total int64, pageNo int32, pageSize int32) *Page {
+	page := &Page{Items: items, Total: total, PageNo: pageNo, PageSize: pageSize}
+	if total >= 0 && pageSize > 0 {
+		page.TotalPages = int32((total + int64(pageSize) - 1) / int64(pageSize))
+		page.HasNext = pageNo < page.TotalPages
+	}
+	return page
+}
+
+// normalize 校验页码和每页记录数，页码小于1时为1
+func (opts PageOptions) normalize(pageNo int32, pageSize int32) (int32, int32, error) {
+	if pageNo < 1 {
+		pageNo = 1
+	}
+	if opts.MaxPageSize > 0 && pageSize > opts.MaxPageSize {
+		pageSize = opts.MaxPageSize
+	}
+	if pageSize <= 0 && !opts.CountOnly {
+		return 0, 0, errors.New("每页记录数需大于0")
+	}
+	if opts.SkipCount && opts.CountOnly {
+		return 0, 0, errors.New("SkipCount与CountOnly不能同时使用")
+	}
+	return pageNo, pageSize, nil
+}
+
+// findPage 按选项并行查询总数和记录
+func findPage(pageNo int32, pageSize int32, opts PageOptions,
+	total func() (Total, error), rows func(pageNo int32, pageSize int32) ([]Row, error)) (*Page, error) {
+	pageNo, pageSize, err := opts.normalize(pageNo, pageSize)
+	if err != nil {
+		return nil, err
+	}
+	if opts.CountOnly {
+		count, err := total()
+		if err != nil {
+			return nil, err
+		}
+		return count.page(nil, pageNo, pageSize), nil
+	}
+	if opts.SkipCount {
+		items, err := rowsWithNext(rows, pageNo, pageSize)
+		if err != nil {
+			return nil, err
+		}
+		page := NewPage(items, -1, pageNo, pageSize)
+		if len(items) > int(pageSize) {
+			page.Items = items[0:pageSize]
+			page.HasNext = true
+		}
+		return page, nil
+	}
+	var count Total
+	var items []Row
+	var err1, err2 error
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		count, err1 = total()
+	}()
+	go func() {
+		defer wg.Done()
+		items, err2 = rows(pageNo, pageSize)
+	}()
+	wg.Wait()
+	if err1 != nil {
+		return nil, err1
+	}
+	if err2 != nil {
+		return nil, err2
+	}
+	return count.page(items, pageNo, pageSize), nil
+}
+
+// page 生成分页结果，超过上限时HasNext按上限计算
+func (t Total) page(items []Row, pageNo int32, pageSize int32) *Page {
+	page := NewPage(items, t.Count, pageNo, pageSize)
+	page.Estimated = t.Estimated
+	page.Capped = t.Capped
+	if t.Capped && pageSize > 0 && int64(pageNo)*int64(pageSize) >= t.Count {
+		page.HasNext = len(items) == int(pageSize)
+	}
+	return page
+}
+
+// rowsWithNext 查询第pageNo页及下一页的第一条记录，用于不查询总数时判断是否有下一页
+func rowsWithNext(rows func(pageNo int32, pageSize int32) ([]Row, error), pageNo int32, pageSize int32) ([]Row, error) {
+	if pageNo == 1 {
+		return rows(1, pageSize+1)
+	}
+	items, err := rows(pageNo, pageSize)
+	if err != nil || len(items) < int(pageSize) {
+		return items, err
+	}
+	next, err := rows(pageNo*pageSize+1, 1)
+	if err != nil {
+		return nil, err
+	}
+	return append(items, next...), nil
+}
+
+// FindPage 分页查询，返回带总页数等信息的分页结果
+func (gm *Gorm) FindPage(model interface{}, pageNo int32, pageSize int32, cons []ConsWrapper, orders []QueryOrder, opts PageOptions) (*Page, error) {
+	return findPage(pageNo, pageSize, opts, func() (Total, error) {
+		return gm.FindPageCount(model, cons, opts.Count)
+	}, func(pageNo int32, pageSize int32) ([]Row, error) {
+		return gm.FindPageRows(model, pageNo, pageSize, cons, orders)
+	})
+}
+
+// FindPageSelect 自定义查询SQL分页查询，总数SQL由 GenCountSQL 生成
+func (gm *Gorm) FindPageSelect(selectSQL string, selectParams []interface{}, pageNo int32, pageSize int32, cons []ConsWrapper, orders []QueryOrder, opts PageOptions) (*Page, error) {
+	return findPage(pageNo, pageSize, opts, func() (Total, error) {
+		return gm.findPageSelectCount(selectSQL, selectParams, cons, opts.Count)
+	}, func(pageNo int32, pageSize int32) ([]Row, error) {
+		return gm.FindPageSelectRows(selectSQL, selectParams, pageNo, pageSize, cons, orders)
+	})
+}
+
+// pageItemFields 未指定记录字段时按顺序查找的repeated字段名
+var pageItemFields = []string{"items", "list", "rows", "data", "records"}
+
+// FillProto 将分页结果写入protobuf分页响应，按字段名或json名匹配 total、pageNo、pageSize、totalPages、hasNext，
+// 记录写入itemsField指定的repeated消息字段，记录字段按名称忽略大小写匹配Row中的列
+func (page *Page) FillProto(msg proto.Message, itemsField ...string) error {
+	m := msg.ProtoReflect()
+	md := m.Descriptor()
+	values := map[string]interface{}{
+		"total":      page.Total,
+		"pageNo":     page.PageNo,
+		"pageSize":   page.PageSize,
+		"totalPages": page.TotalPages,
+		"hasNext":    page.HasNext,
+	}
+	for name, value := range values {
+		if fd := protoField(md, name); fd != nil && !fd.IsList() && !fd.IsMap() {
+			if v, ok := toProtoValue(fd, value); ok {
+				m.Set(fd, v)
+			}
+		}
+	}
+	names := pageItemFields
+	if len(itemsField) > 0 {
+		names = itemsField
+	}
+	var fd protoreflect.FieldDescriptor
+	for _, v := range names {
+		if fd = protoField(md, v); fd != nil {
+			break
+		}
+	}
+	if fd == nil {
+		if len(page.Items) == 0 {
+			return nil
+		}
+		return fmt.Errorf("%s中没有记录字段", md.FullName())
+	}
+	if !fd.IsList() || fd.Kind() != protoreflect.MessageKind {
+		return fmt.Errorf("%s不是repeated消息字段", fd.Name())
+	}
+	list := m.Mutable(fd).List()
+	for _, row := range page.Items {
+		item := list.NewElement()
+		if err := FillProtoRow(item.Message().Interface(), row); err != nil {
+			return err
+		}
+		list.Append(item)
+	}
+	return nil
+}
+
+// FillProtoRow 将记录写入protobuf消息，Timestamp字段支持时间及字符串，bool字段支持0/1
+func FillProtoRow(msg proto.Message, row Row) error {
+	m := msg.ProtoReflect()
+	fields := m.Descriptor().Fields()
+	for i := 0; i < fields.Len(); i++ {
+		fd := fields.Get(i)
+		if fd.IsList() || fd.IsMap() {
+			continue
+		}
+		key := string(fd.Name())
+		if !row.ContainsKey(key) {
+			key = fd.JSONName()
+		}
+		value := row.GetInterface(key)
+		if value == nil {
+			continue
+		}
+		if fd.Kind() == protoreflect.MessageKind && fd.Message().FullName() == "google.protobuf.Timestamp" {
+			ts, err := toDataTime(value)
+			if err != nil {
+				return fmt.Errorf("%s:%v", fd.Name(), err)
+			}
+			if ts != nil {
+				m.Set(fd, protoreflect.ValueOfMessage(ts.ProtoReflect()))
+			}
+			continue
+		}
+		v, ok := toProtoValue(fd, value)
+		if !ok {
+			return fmt.Errorf("%s的值%v类型不匹配", fd.Name(), value)
+		}
+		m.Set(fd, v)
+	}
+	return nil
+}
+
+func toProtoValue(fd protoreflect.FieldDescriptor, value interface{}) (protoreflect.Value, bool) {
+	if b, ok := value.([]byte); ok && fd.Kind() != protoreflect.BytesKind {
+		value = string(b)
+	}
+	switch fd.Kind() {
+	case protoreflect.BoolKind:
+		v, err := cast.ToBoolE(value)
+		return protoreflect.ValueOfBool(v), err == nil
+	case protoreflect.EnumKind:
+		v, err := cast.ToInt32E(value)
+		return protoreflect.ValueOfEnum(protoreflect.EnumNumber(v)), err == nil
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind:
+		v, err := cast.ToInt32E(value)
+		return protoreflect.ValueOfInt32(v), err == nil
+	case protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
+		v, err := cast.ToInt64E(value)
+		return protoreflect.ValueOfInt64(v), err == nil
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind:
+		v, err := cast.ToUint32E(value)
+		return protoreflect.ValueOfUint32(v), err == nil
+	case protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		v, err := cast.ToUint64E(value)
+		return protoreflect.ValueOfUint64(v), err == nil
+	case protoreflect.FloatKind:
+		v, err := cast.ToFloat32E(value)
+		return protoreflect.ValueOfFloat32(v), err == nil
+	case protoreflect.DoubleKind:
+		v, err := cast.ToFloat64E(value)
+		return protoreflect.ValueOfFloat64(v), err == nil
+	case protoreflect.StringKind:
+		v, err := cast.ToStringE(value)
+		return protoreflect.ValueOfString(v), err == nil
+	case protoreflect.BytesKind:
+		if b, ok := value.([]byte); ok {
+			return protoreflect.ValueOfBytes(b), true
+		}
+		return protoreflect.ValueOfBytes([]byte(cast.ToString(value))), true
+	default:
+		return protoreflect.Value{}, false
+	}
+}
@@ -0,0 +1,129 @@
+package gorm
+
+import (
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNewPage(t *testing.T) {
+	page := NewPage(nil, 21, 2, 10)
+	if page.TotalPages != 3 || !page.HasNext {
+		t.Fatalf("page=%+v", page)
+	}
+	if page = NewPage(nil, 20, 2, 10); page.TotalPages != 2 || page.HasNext {
+		t.Fatalf("page=%+v", page)
+	}
+	if _, _, err := (PageOptions{}).normalize(1, 0); err == nil {
+		t.Fatalf("zero page size must be rejected")
+	}
+	if pageNo, pageSize, _ := (PageOptions{MaxPageSize: 100}).normalize(0, 1000); pageNo != 1 || pageSize != 100 {
+		t.Fatalf("pageNo=%d,pageSize=%d", pageNo, pageSize)
+	}
+}
+
+func TestFindPage_SkipCount(t *testing.T) {
+	data := make([]Row, 25)
+	for k := range data {
+		data[k] = NewRow(map[string]interface{}{"id": k})
+	}
+	rows := func(pageNo int32, pageSize int32) ([]Row, error) {
+		begin := int((pageNo - 1) * pageSize)
+		end := begin + int(pageSize)
+		if begin > len(data) {
+			begin = len(data)
+		}
+		if end > len(data) {
+			end = len(data)
+		}
+		return data[begin:end], nil
+	}
+	total := func() (Total, error) {
+		t.Fatalf("total must not be queried")
+		return Total{}, nil
+	}
+	testdatas := []struct {
+		pageNo  int32
+		items   int
+		hasNext bool
+	}{{1, 10, true}, {2, 10, true}, {3, 5, false}}
+	for _, v := range testdatas {
+		page, err := findPage(v.pageNo, 10, PageOptions{SkipCount: true}, total, rows)
+		if err != nil || len(page.Items) != v.items || page.HasNext != v.hasNext || page.Total != -1 {
+			t.Fatalf("pageNo=%d,page=%+v,err=%v", v.pageNo, page, err)
+		}
+		if page.Items[0].GetInt("id") != int(v.pageNo-1)*10 {
+			t.Fatalf("pageNo=%d,first=%v", v.pageNo, page.Items[0].GetData())
+		}
+	}
+}
+
+func TestPage_FillProto(t *testing.T) {
+	field := func(name string, number int32, kind descriptorpb.FieldDescriptorProto_Type, label descriptorpb.FieldDescriptorProto_Label, typeName string) *descriptorpb.FieldDescriptorProto {
+		fd := &descriptorpb.FieldDescriptorProto{Name: proto.String(name), Number: proto.Int32(number), Type: kind.Enum(), Label: label.Enum()}
+		if typeName != "" {
+			fd.TypeName = proto.String(typeName)
+		}
+		return fd
+	}
+	optional := descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL
+	repeated := descriptorpb.FieldDescriptorProto_LABEL_REPEATED
+	fdp := &descriptorpb.FileDescriptorProto{
+		Name:       proto.String("test/page.proto"),
+		Package:    proto.String("test"),
+		Syntax:     proto.String("proto3"),
+		Dependency: []string{"google/protobuf/timestamp.proto"},
+		MessageType: []*descriptorpb.DescriptorProto{{
+			Name: proto.String("Item"),
+			Field: []*descriptorpb.FieldDescriptorProto{
+				field("id", 1, descriptorpb.FieldDescriptorProto_TYPE_INT64, optional, ""),
+				field("name", 2, descriptorpb.FieldDescriptorProto_TYPE_STRING, optional, ""),
+				field("enable", 3, descriptorpb.FieldDescriptorProto_TYPE_BOOL, optional, ""),
+				field("create_time", 4, descriptorpb.FieldDescriptorProto_TYPE_MESSAGE, optional, ".google.protobuf.Timestamp"),
+			},
+		}, {
+			Name: proto.String("PageResp"),
+			Field: []*descriptorpb.FieldDescriptorProto{
+				field("total", 1, descriptorpb.FieldDescriptorProto_TYPE_INT64, optional, ""),
+				field("page_no", 2, descriptorpb.FieldDescriptorProto_TYPE_INT32, optional, ""),
+				field("has_next", 3, descriptorpb.FieldDescriptorProto_TYPE_BOOL, optional, ""),
+				field("list", 4, descriptorpb.FieldDescriptorProto_TYPE_MESSAGE, repeated, ".test.Item"),
+			},
+		}},
+	}
+	fd, err := protodesc.NewFile(fdp, protoregistry.GlobalFiles)
+	if err != nil {
+		t.Fatalf("descriptor.err=%v", err)
+	}
+	md := fd.Messages().ByName("PageResp")
+	msg := dynamicpb.NewMessage(md)
+	now := time.Date(2024, 1, 1, 8, 0, 0, 0, time.Local)
+	page := NewPage([]Row{NewRow(map[string]interface{}{"ID": int32(1), "name": []byte("张"), "enable": int64(1), "createTime": now})}, 11, 1, 10)
+	if err = page.FillProto(msg); err != nil {
+		t.Fatalf("fill.err=%v", err)
+	}
+	fields := md.Fields()
+	if msg.Get(fields.ByName("total")).Int() != 11 || msg.Get(fields.ByName("page_no")).Int() != 1 || !msg.Get(fields.ByName("has_next")).Bool() {
+		t.Fatalf("msg=%v", msg)
+	}
+	list := msg.Get(fields.ByName("list")).List()
+	if list.Len() != 1 {
+		t.Fatalf("list=%v", list)
+	}
+	item := list.Get(0).Message()
+	itemFields := item.Descriptor().Fields()
+	ts := item.Get(itemFields.ByName("create_time")).Message()
+	if item.Get(itemFields.ByName("id")).Int() != 1 || item.Get(itemFields.ByName("name")).String() != "张" ||
+		!item.Get(itemFields.ByName("enable")).Bool() || ts.Get(ts.Descriptor().Fields().ByNumber(1)).Int() != now.Unix() {
+		t.Fatalf("item=%v", item)
+	}
+	page = NewPage([]Row{NewRow(map[string]interface{}{"id": 1, "createTime": true})}, 1, 1, 10)
+	if err = page.FillProto(dynamicpb.NewMessage(md)); err == nil || !strings.Contains(err.Error(), "类型不匹配") {
+		t.Fatalf("time type err=%v", err)
+	}
+}
@@ -0,0 +1,348 @@
+package gorm
+
+import (
+	"fmt"
+	"github.com/samber/lo"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"strconv"
+	"strings"
+)
+
+// ProtoField 请求消息字段与查询条件的映射
+type ProtoField struct {
+	Field     string // proto字段名或json名
+	Column    string // 数据库字段，为空时与Field相同
+	Compare   int    // 比较类型，repeated字段为CompareEqual时按CompareIn处理
+	Or        bool   // 是否放入OR条件组
+	Threshold *int64 // 数值阈值，大于阈值才生成条件，同IntThreshold
+}
+
+// ProtoMapping 请求消息到查询条件的映射表
+type ProtoMapping struct {
+	Fields     []ProtoField
+	OrderField string            // 排序字段，字符串或repeated字符串，如 "name,-createTime" 或 "createTime desc"
+	Sorts      map[string]string // 允许排序的名称到数据库字段的映射，指定OrderField时必须设置，不在其中的排序忽略
+	// Option 字符串类型的字段选项扩展(extend google.protobuf.FieldOptions)，设置了该选项的字段按选项生成条件，
+	// 格式为 "column=createTime;op=ge;or;threshold=1"，op同JSON条件操作符，默认eq；Fields中已有的字段以Fields为准
+	//
+	//	extend google.protobuf.FieldOptions { string cons = 50001; }
+	//	message ListReq { google.protobuf.Timestamp begin = 1 [(cons) = "column=createTime;op=ge"]; }
+	Option protoreflect.ExtensionType
+}
+
+// ProtoCons 按映射表读取请求消息生成查询条件和排序，零值规则与 StrCondition、Int32Condition、TimeConstraint 等一致：
+// 空字符串、0不生成条件，-1转换为0；bool为true时为1；Timestamp为nil不生成条件；repeated字段生成IN或NOT IN条件，不支持其它比较；
+// proto3 optional及包装类型(wrapperspb)以是否设置为准，不做零值过滤。
+func ProtoCons(msg proto.Message, mapping ProtoMapping) ([]ConsWrapper, []QueryOrder, error) {
+	if msg == nil {
+		return nil, nil, nil
+	}
+	m := msg.ProtoReflect()
+	fields, err := protoOptionFields(m.Descriptor(), mapping)
+	if err != nil {
+		return nil, nil, err
+	}
+	var wrapper, or []ConsWrapper
+	for _, v := range fields {
+		fd := protoField(m.Descriptor(), v.Field)
+		if fd == nil {
+			return nil, nil, fmt.Errorf("%s中不存在字段%s", m.Descriptor().FullName(), v.Field)
+		}
+		column := lo.Ternary(v.Column == "", v.Field, v.Column)
+		cons, ok, err := protoCons(m, fd, column, v)
+		if err != nil {
+			return nil, nil, err
+		}
+		if !ok {
+			continue
+		}
+		if v.Or {
+			or = append(or, cons)
+		} else {
+			wrapper = append(wrapper, cons)
+		}
+	}
+	if len(or) > 0 {
+		wrapper = append(wrapper, OrConstraint{Wrapper: or})
+	}
+	orders, err := protoOrders(m, mapping)
+	return wrapper, orders, err
+}
+
+// protoOptionFields 合并映射表字段及设置了字段选项的字段
+func protoOptionFields(md protoreflect.MessageDescriptor, mapping ProtoMapping) ([]ProtoField, error) {
+	if mapping.Option == nil {
+		return mapping.Fields, nil
+	}
+	fields := mapping.Fields
+	for i := 0; i < md.Fields().Len(); i++ {
+		fd := md.Fields().Get(i)
+		if lo.ContainsBy(mapping.Fields, func(v ProtoField) bool {
+			return protoField(md, v.Field) == fd
+		}) {
+			continue
+		}
+		option, ok := protoFieldOption(fd, mapping.Option)
+		if !ok {
+			continue
+		}
+		field, err := parseProtoOption(string(fd.Name()), option)
+		if err != nil {
+			return nil, err
+		}
+		fields = append(fields, field)
+	}
+	return fields, nil
+}
+
+// protoFieldOption 读取字段选项，选项扩展未注册时从未知字段中解析
+func protoFieldOption(fd protoreflect.FieldDescriptor, xt protoreflect.ExtensionType) (string, bool) {
+	opts, ok := fd.Options().(*descriptorpb.FieldOptions)
+	if !ok || opts == nil {
+		return "", false
+	}
+	if !proto.HasExtension(opts, xt) && len(opts.ProtoReflect().GetUnknown()) > 0 {
+		data, err := proto.Marshal(opts)
+		if err != nil {
+			return "", false
+		}
+		types := new(protoregistry.Types)
+		if err = types.RegisterExtension(xt); err != nil {
+			return "", false
+		}
+		opts = &descriptorpb.FieldOptions{}
+		if err = (proto.UnmarshalOptions{Resolver: types}).Unmarshal(data, opts); err != nil {
+			return "", false
+		}
+	}
+	if !proto.HasExtension(opts, xt) {
+		return "", false
+	}
+	option, ok := proto.GetExtension(opts, xt).(string)
+	return option, ok && option != ""
+}
+
+// parseProtoOption 解析字段选项 "column=createTime;op=ge;or;threshold=1"
+func parseProtoOption(name string, option string) (ProtoField, error) {
+	field := ProtoField{Field: name}
+	for _, v := range strings.Split(option, ";") {
+		key, value, _ := strings.Cut(strings.TrimSpace(v), "=")
+		switch key {
+		case "":
+		case "or":
+			field.Or = true
+		case "column":
+			field.Column = value
+		case "op":
+			compare, ok := ParseOperator(value)
+			if !ok {
+				return field, fmt.Errorf("%s: 不支持的操作符%q", name, value)
+			}
+			field.Compare = compare
+		case "threshold":
+			threshold, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				return field, fmt.Errorf("%s: 阈值%q不是整数", name, value)
+			}
+			field.Threshold = &threshold
+		default:
+			return field, fmt.Errorf("%s: 不支持的字段选项%q", name, v)
+		}
+	}
+	return field, nil
+}
+
+func protoField(md protoreflect.MessageDescriptor, name string) protoreflect.FieldDescriptor {
+	fields := md.Fields()
+	if fd := fields.ByName(protoreflect.Name(name)); fd != nil {
+		return fd
+	}
+	if fd := fields.ByJSONName(name); fd != nil {
+		return fd
+	}
+	for i := 0; i < fields.Len(); i++ {
+		if strings.EqualFold(string(fields.Get(i).Name()), name) {
+			return fields.Get(i)
+		}
+	}
+	return nil
+}
+
+func protoCons(m protoreflect.Message, fd protoreflect.FieldDescriptor, column string, field ProtoField) (ConsWrapper, bool, error) {
+	if fd.IsMap() {
+		return nil, false, nil
+	}
+	if fd.IsList() {
+		var compare int
+		switch field.Compare {
+		case CompareEqual, CompareIn:
+			compare = CompareIn
+		case CompareNotEqual, CompareNotIn:
+			compare = CompareNotIn
+		default:
+			return nil, false, fmt.Errorf("repeated字段%s不支持%s比较", fd.Name(), GetSymbol(field.Compare))
+		}
+		list := m.Get(fd).List()
+		if list.Len() == 0 {
+			return nil, false, nil
+		}
+		values := make([]interface{}, 0, list.Len())
+		for i := 0; i < list.Len(); i++ {
+			v, ok := protoValue(fd, list.Get(i))
+			if !ok {
+				return nil, false, fmt.Errorf("repeated字段%s的元素类型%s不支持", fd.Name(), fd.Kind())
+			}
+			values = append(values, v)
+		}
+		return GenCons(column, values, compare), true, nil
+	}
+	if fd.Kind() == protoreflect.MessageKind || fd.Kind() == protoreflect.GroupKind {
+		if !m.Has(fd) {
+			return nil, false, nil
+		}
+		sub := m.Get(fd).Message()
+		switch sub.Descriptor().FullName() {
+		case "google.protobuf.Timestamp":
+			ts := &timestamppb.Timestamp{
+				Seconds: sub.Get(sub.Descriptor().Fields().ByNumber(1)).Int(),
+				Nanos:   int32(sub.Get(sub.Descriptor().Fields().ByNumber(2)).Int()),
+			}
+			wrapper := TimeConstraint(nil, column, ts, field.Compare)
+			return wrapper[0], true, nil
+		}
+		if strings.HasPrefix(string(sub.Descriptor().FullName()), "google.protobuf.") && strings.HasSuffix(string(sub.Descriptor().Name()), "Value") {
+			if vfd := sub.Descriptor().Fields().ByName("value"); vfd != nil {
+				if v, ok := protoValue(vfd, sub.Get(vfd)); ok {
+					return GenCons(column, v, field.Compare), true, nil
+				}
+			}
+		}
+		return nil, false, nil
+	}
+	value, ok := protoValue(fd, m.Get(fd))
+	if !ok {
+		return nil, false, nil
+	}
+	if fd.HasPresence() {
+		if !m.Has(fd) {
+			return nil, false, nil
+		}
+		if b, isBool := value.(bool); isBool {
+			value = lo.Ternary(b, 1, 0)
+		}
+		return GenCons(column, value, field.Compare), true, nil
+	}
+	constraint := make(map[string]interface{})
+	switch value.(type) {
+	case string:
+		StrCondition(constraint, column, value.(string))
+	case bool:
+		if value.(bool) {
+			constraint[column] = 1
+		}
+	case float32, float64:
+		if fmt.Sprint(value) != "0" {
+			constraint[column] = value
+		}
+	default:
+		if field.Threshold != nil {
+			IntThreshold(constraint, column, value, int(*field.Threshold))
+		} else {
+			IntCondition(constraint, column, value)
+		}
+	}
+	if v, has := constraint[column]; has {
+		return GenCons(column, v, field.Compare), true, nil
+	}
+	return nil, false, nil
+}
+
+func protoValue(fd protoreflect.FieldDescriptor, v protoreflect.Value) (interface{}, bool) {
+	switch fd.Kind() {
+	case protoreflect.BoolKind:
+		return v.Bool(), true
+	case protoreflect.EnumKind:
+		return int32(v.Enum()), true
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind:
+		return int32(v.Int()), true
+	case protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
+		return v.Int(), true
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind:
+		return uint32(v.Uint()), true
+	case protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		return v.Uint(), true
+	case protoreflect.FloatKind:
+		return float32(v.Float()), true
+	case protoreflect.DoubleKind:
+		return v.Float(), true
+	case protoreflect.StringKind:
+		return v.String(), true
+	default:
+		return nil, false
+	}
+}
+
+func protoOrders(m protoreflect.Message, mapping ProtoMapping) ([]QueryOrder, error) {
+	if mapping.OrderField == "" {
+		return nil, nil
+	}
+	if mapping.Sorts == nil {
+		return nil, fmt.Errorf("排序字段%s需要指定Sorts白名单", mapping.OrderField)
+	}
+	fd := protoField(m.Descriptor(), mapping.OrderField)
+	if fd == nil || fd.Kind() != protoreflect.StringKind {
+		return nil, fmt.Errorf("%s中不存在字符串排序字段%s", m.Descriptor().FullName(), mapping.OrderField)
+	}
+	var specs []string
+	if fd.IsList() {
+		list := m.Get(fd).List()
+		for i := 0; i < list.Len(); i++ {
+			specs = append(specs, strings.Split(list.Get(i).String(), ",")...)
+		}
+	} else {
+		specs = strings.Split(m.Get(fd).String(), ",")
+	}
+	var orders []QueryOrder
+	for _, v := range specs {
+		if order, ok := toQueryOrder(v, mapping.Sorts); ok {
+			orders = append(orders, order)
+		}
+	}
+	return orders, nil
+}
+
+// toQueryOrder 解析排序描述，支持 name、-name、+name、name desc、name asc
+func toQueryOrder(spec string, sorts map[string]string) (QueryOrder, bool) {
+	fields := strings.Fields(spec)
+	if len(fields) == 0 || len(fields) > 2 {
+		return QueryOrder{}, false
+	}
+	name, asc := fields[0], true
+	if strings.HasPrefix(name, "-") {
+		name, asc = name[1:], false
+	} else {
+		name = strings.TrimPrefix(name, "+")
+	}
+	if len(fields) == 2 {
+		switch strings.ToLower(fields[1]) {
+		case "asc":
+			asc = true
+		case "desc":
+			asc = false
+		default:
+			return QueryOrder{}, false
+		}
+	}
+	if name == "" {
+		return QueryOrder{}, false
+	}
+	column, ok := sorts[name]
+	if !ok {
+		return QueryOrder{}, false
+	}
+	return QueryOrder{FieldName: column, Asc: asc}, true
+}
@@ -0,0 +1,187 @@
+package gorm
+
+import (
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"strings"
+	"testing"
+	"time"
+)
+
+func listRequest(t *testing.T) protoreflect.MessageDescriptor {
+	field := func(name string, number int32, kind descriptorpb.FieldDescriptorProto_Type, label descriptorpb.FieldDescriptorProto_Label, typeName string) *descriptorpb.FieldDescriptorProto {
+		fd := &descriptorpb.FieldDescriptorProto{Name: proto.String(name), Number: proto.Int32(number), Type: kind.Enum(), Label: label.Enum(), JsonName: proto.String(name)}
+		if typeName != "" {
+			fd.TypeName = proto.String(typeName)
+		}
+		return fd
+	}
+	optional := descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL
+	repeated := descriptorpb.FieldDescriptorProto_LABEL_REPEATED
+	fdp := &descriptorpb.FileDescriptorProto{
+		Name:       proto.String("test/list.proto"),
+		Package:    proto.String("test"),
+		Syntax:     proto.String("proto3"),
+		Dependency: []string{"google/protobuf/timestamp.proto"},
+		MessageType: []*descriptorpb.DescriptorProto{{
+			Name: proto.String("ListReq"),
+			Field: []*descriptorpb.FieldDescriptorProto{
+				field("name", 1, descriptorpb.FieldDescriptorProto_TYPE_STRING, optional, ""),
+				field("type", 2, descriptorpb.FieldDescriptorProto_TYPE_INT32, optional, ""),
+				field("enable", 3, descriptorpb.FieldDescriptorProto_TYPE_INT32, optional, ""),
+				field("ids", 4, descriptorpb.FieldDescriptorProto_TYPE_INT64, repeated, ""),
+				field("begin", 5, descriptorpb.FieldDescriptorProto_TYPE_MESSAGE, optional, ".google.protobuf.Timestamp"),
+				field("end", 6, descriptorpb.FieldDescriptorProto_TYPE_MESSAGE, optional, ".google.protobuf.Timestamp"),
+				field("sort", 7, descriptorpb.FieldDescriptorProto_TYPE_STRING, optional, ""),
+				field("audit", 8, descriptorpb.FieldDescriptorProto_TYPE_INT64, optional, ""),
+				field("codes", 9, descriptorpb.FieldDescriptorProto_TYPE_BYTES, repeated, ""),
+			},
+		}},
+	}
+	fd, err := protodesc.NewFile(fdp, protoregistry.GlobalFiles)
+	if err != nil {
+		t.Fatalf("descriptor.err=%v", err)
+	}
+	return fd.Messages().Get(0)
+}
+
+func TestProtoCons(t *testing.T) {
+	md := listRequest(t)
+	msg := dynamicpb.NewMessage(md)
+	fields := md.Fields()
+	begin := time.Date(2024, 1, 1, 0, 0, 0, 0, time.Local)
+	msg.Set(fields.ByName("name"), protoreflect.ValueOfString("张"))
+	msg.Set(fields.ByName("enable"), protoreflect.ValueOfInt32(-1))
+	ids := msg.Mutable(fields.ByName("ids")).List()
+	ids.Append(protoreflect.ValueOfInt64(1))
+	ids.Append(protoreflect.ValueOfInt64(2))
+	msg.Set(fields.ByName("begin"), protoreflect.ValueOfMessage(timestamppb.New(begin).ProtoReflect()))
+	msg.Set(fields.ByName("sort"), protoreflect.ValueOfString("name,-createTime,bad desc,x y z"))
+	msg.Set(fields.ByName("audit"), protoreflect.ValueOfInt64(1))
+	threshold := int64(1)
+	cons, orders, err := ProtoCons(msg, ProtoMapping{
+		Fields: []ProtoField{
+			{Field: "name", Compare: CompareLike},
+			{Field: "type"},
+			{Field: "enable"},
+			{Field: "ids", Column: "id"},
+			{Field: "begin", Column: "createTime", Compare: CompareGreaterThanOrEqual},
+			{Field: "end", Column: "createTime", Compare: CompareLessThanOrEqual},
+			{Field: "audit", Threshold: &threshold},
+		},
+		OrderField: "sort",
+		Sorts:      map[string]string{"name": "name", "createTime": "createTime"},
+	})
+	if err != nil {
+		t.Fatalf("proto.err=%v", err)
+	}
+	var build strings.Builder
+	params := GenWhereSQL(&build, cons)
+	GenOrderSQL(&build, orders)
+	expect := " AND (name LIKE ? ESCAPE '\\\\' AND enable = ? AND id IN (?,?) AND createTime >= ?) ORDER BY name ASC,createTime DESC"
+	if build.String() != expect {
+		t.Fatalf("sql=%s,expect=%s", build.String(), expect)
+	}
+	if params[0] != "%张%" || params[1] != 0 || params[4] != "2024-01-01 00:00:00" {
+		t.Fatalf("params=%v", params)
+	}
+	if _, _, err = ProtoCons(msg, ProtoMapping{Fields: []ProtoField{{Field: "none"}}}); err == nil {
+		t.Fatalf("unknown field must be rejected")
+	}
+	if _, _, err = ProtoCons(msg, ProtoMapping{OrderField: "sort"}); err == nil {
+		t.Fatalf("order field without sorts must be rejected")
+	}
+	if _, _, err = ProtoCons(msg, ProtoMapping{Fields: []ProtoField{{Field: "ids", Compare: CompareLike}}}); err == nil {
+		t.Fatalf("repeated field with like must be rejected")
+	}
+	msg.Mutable(fields.ByName("codes")).List().Append(protoreflect.ValueOfBytes([]byte("a")))
+	if _, _, err = ProtoCons(msg, ProtoMapping{Fields: []ProtoField{{Field: "codes"}}}); err == nil {
+		t.Fatalf("repeated bytes field must be rejected")
+	}
+	cons, _, err = ProtoCons(msg, ProtoMapping{Fields: []ProtoField{{Field: "ids", Column: "id", Compare: CompareNotEqual}}})
+	build.Reset()
+	if GenWhereSQL(&build, cons); err != nil || build.String() != " AND (id NOT IN (?,?))" {
+		t.Fatalf("sql=%s,err=%v", build.String(), err)
+	}
+}
+
+func TestProtoCons_Option(t *testing.T) {
+	extFile, err := protodesc.NewFile(&descriptorpb.FileDescriptorProto{
+		Name:       proto.String("test/option.proto"),
+		Package:    proto.String("test"),
+		Syntax:     proto.String("proto3"),
+		Dependency: []string{"google/protobuf/descriptor.proto"},
+		Extension: []*descriptorpb.FieldDescriptorProto{{
+			Name: proto.String("cons"), Number: proto.Int32(50001), JsonName: proto.String("cons"),
+			Type:     descriptorpb.FieldDescriptorProto_TYPE_STRING.Enum(),
+			Label:    descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+			Extendee: proto.String(".google.protobuf.FieldOptions"),
+		}},
+	}, protoregistry.GlobalFiles)
+	if err != nil {
+		t.Fatalf("extension.err=%v", err)
+	}
+	xt := dynamicpb.NewExtensionType(extFile.Extensions().Get(0))
+	option := func(value string, unknown bool) *descriptorpb.FieldOptions {
+		opts := &descriptorpb.FieldOptions{}
+		proto.SetExtension(opts, xt, value)
+		if unknown {
+			// 未注册扩展时生成代码中的选项为未知字段
+			data, _ := proto.Marshal(opts)
+			opts = &descriptorpb.FieldOptions{}
+			if err := (proto.UnmarshalOptions{Resolver: new(protoregistry.Types)}).Unmarshal(data, opts); err != nil {
+				t.Fatalf("unmarshal.err=%v", err)
+			}
+		}
+		return opts
+	}
+	files := new(protoregistry.Files)
+	files.RegisterFile(timestamppb.File_google_protobuf_timestamp_proto)
+	files.RegisterFile(extFile)
+	optional := descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum()
+	fd, err := protodesc.NewFile(&descriptorpb.FileDescriptorProto{
+		Name:       proto.String("test/option_req.proto"),
+		Package:    proto.String("test"),
+		Syntax:     proto.String("proto3"),
+		Dependency: []string{"google/protobuf/timestamp.proto", "test/option.proto"},
+		MessageType: []*descriptorpb.DescriptorProto{{
+			Name: proto.String("OptionReq"),
+			Field: []*descriptorpb.FieldDescriptorProto{
+				{Name: proto.String("name"), Number: proto.Int32(1), Type: descriptorpb.FieldDescriptorProto_TYPE_STRING.Enum(), Label: optional, JsonName: proto.String("name"), Options: option("op=like", false)},
+				{Name: proto.String("begin"), Number: proto.Int32(2), Type: descriptorpb.FieldDescriptorProto_TYPE_MESSAGE.Enum(), Label: optional, JsonName: proto.String("begin"), TypeName: proto.String(".google.protobuf.Timestamp"), Options: option("column=createTime;op=ge", true)},
+				{Name: proto.String("type"), Number: proto.Int32(3), Type: descriptorpb.FieldDescriptorProto_TYPE_INT32.Enum(), Label: optional, JsonName: proto.String("type")},
+				{Name: proto.String("audit"), Number: proto.Int32(4), Type: descriptorpb.FieldDescriptorProto_TYPE_INT32.Enum(), Label: optional, JsonName: proto.String("audit"), Options: option("op=eq;or", false)},
+			},
+		}},
+	}, files)
+	if err != nil {
+		t.Fatalf("descriptor.err=%v", err)
+	}
+	md := fd.Messages().Get(0)
+	msg := dynamicpb.NewMessage(md)
+	msg.Set(md.Fields().ByName("name"), protoreflect.ValueOfString("张"))
+	msg.Set(md.Fields().ByName("begin"), protoreflect.ValueOfMessage(timestamppb.New(time.Date(2024, 1, 1, 0, 0, 0, 0, time.Local)).ProtoReflect()))
+	msg.Set(md.Fields().ByName("type"), protoreflect.ValueOfInt32(1))
+	msg.Set(md.Fields().ByName("audit"), protoreflect.ValueOfInt32(1))
+	cons, _, err := ProtoCons(msg, ProtoMapping{Fields: []ProtoField{{Field: "audit", Compare: CompareGreaterThan}}, Option: xt})
+	if err != nil {
+		t.Fatalf("proto.err=%v", err)
+	}
+	var build strings.Builder
+	params := GenWhereSQL(&build, cons)
+	expect := " AND (audit > ? AND name LIKE ? ESCAPE '\\\\' AND createTime >= ?)"
+	if build.String() != expect || len(params) != 3 {
+		t.Fatalf("sql=%s,expect=%s", build.String(), expect)
+	}
+	if _, err = parseProtoOption("name", "op=regexp"); err == nil {
+		t.Fatalf("unknown operator must be rejected")
+	}
+	if _, err = parseProtoOption("name", "size=1"); err == nil {
+		t.Fatalf("unknown option must be rejected")
+	}
+}
@@ -0,0 +1,238 @@
+package gorm
+
+import (
+	"errors"
+	"gitops.sudytech.cn/guolei/gorm/sys"
+)
+
+// QueryBuilder 链式查询，生成 ConsWrapper/QueryOrder 后通过 FindPage* 执行，字段按模型白名单校验
+//
+//	rows, total, err := gm.Query(&Algorithm{}).
+//		Where(Eq("type", 1), Like("name", "张")).
+//		Or(Eq("audit", 0), IsNull("audit")).
+//		Desc("createTime").
+//		Page(1, 20)
+type QueryBuilder struct {
+	gm      *Gorm
+	model   interface{}
+	columns *ColumnSet
+	wrapper []ConsWrapper
+	orders  []QueryOrder
+}
+
+// Query 使用 GetConn() 的连接创建链式查询
+func Query(model interface{}) *QueryBuilder {
+	return &QueryBuilder{model: model}
+}
+
+func (gm *Gorm) Query(model interface{}) *QueryBuilder {
+	return &QueryBuilder{gm: gm, model: model}
+}
+
+// Columns 指定字段白名单，默认使用模型gorm标签，model为表名字符串时必须指定
+func (qb *QueryBuilder) Columns(cs *ColumnSet) *QueryBuilder {
+	qb.columns = cs
+	return qb
+}
+
+// Where 添加AND条件
+func (qb *QueryBuilder) Where(cons ...ConsWrapper) *QueryBuilder {
+	qb.wrapper = append(qb.wrapper, cons...)
+	return qb
+}
+
+// Or 添加一组OR条件，该组整体与其它条件AND
+func (qb *QueryBuilder) Or(cons ...ConsWrapper) *QueryBuilder {
+	if len(cons) > 0 {
+		qb.wrapper = append(qb.wrapper, OrConstraint{Wrapper: cons})
+	}
+	return qb
+}
+
+func (qb *QueryBuilder) OrderBy(orders ...QueryOrder) *QueryBuilder {
+	qb.orders = append(qb.orders, orders...)
+	return qb
+}
+
+func (qb *QueryBuilder) Asc(names ...string) *QueryBuilder {
+	for _, v := range names {
+		qb.orders = append(qb.orders, QueryOrder{FieldName: v, Asc: true})
+	}
+	return qb
+}
+
+func (qb *QueryBuilder) Desc(names ...string) *QueryBuilder {
+	for _, v := range names {
+		qb.orders = append(qb.orders, QueryOrder{FieldName: v, Asc: false})
+	}
+	return qb
+}
+
+// Build 校验字段并返回生成的条件和排序
+func (qb *QueryBuilder) Build() ([]ConsWrapper, []QueryOrder, sys.IGormErr) {
+	gm := qb.conn()
+	if gm == nil {
+		return nil, nil, sys.NewMessage(sys.FailCode, "数据库连接未初始化")
+	}
+	cs := qb.columns
+	if cs == nil {
+		if _, ok := qb.model.(string); ok {
+			return nil, nil, sys.NewMessage(sys.PropNotAllowCode, "表名查询需要指定字段白名单")
+		}
+		cs = ModelColumns(qb.model)
+	}
+	cons, err := gm.SafeCons(cs, qb.wrapper)
+	if err != nil {
+		return nil, nil, err
+	}
+	orders, err := gm.SafeOrders(cs, qb.orders)
+	if err != nil {
+		return nil, nil, err
+	}
+	return cons, orders, nil
+}
+
+func (qb *QueryBuilder) conn() *Gorm {
+	if qb.gm != nil {
+		return qb.gm
+	}
+	return GetConn()
+}
+
+func (qb *QueryBuilder) build() ([]ConsWrapper, []QueryOrder, error) {
+	cons, orders, err := qb.Build()
+	if err != nil {
+		return nil, nil, errors.New(err.GetMessage())
+	}
+	return cons, orders, nil
+}
+
+// List 查询全部记录
+func (qb *QueryBuilder) List() ([]Row, error) {
+	cons, orders, err := qb.build()
+	if err != nil {
+		return nil, err
+	}
+	return qb.conn().FindPageRows(qb.model, 0, 0, cons, orders)
+}
+
+func (qb *QueryBuilder) Count() (int64, error) {
+	cons, _, err := qb.build()
+	if err != nil {
+		return 0, err
+	}
+	return qb.conn().FindPageTotal(qb.model, cons)
+}
+
+// Page 分页查询记录及总数，pageNo从1开始，同 FindPageList
+func (qb *QueryBuilder) Page(pageNo int32, pageSize int32) ([]Row, int64, error) {
+	cons, orders, err := qb.build()
+	if err != nil {
+		return nil, 0, err
+	}
+	return qb.conn().FindPageList(qb.model, pageNo, pageSize, cons, orders)
+}
+
+// First 返回排序后的第一条记录，不存在时返回空Row
+func (qb *QueryBuilder) First() (Row, error) {
+	cons, orders, err := qb.build()
+	if err != nil {
+		return NewRow(nil), err
+	}
+	rows, err := qb.conn().FindPageRows(qb.model, 1, 1, cons, orders)
+	if err != nil || len(rows) == 0 {
+		return NewRow(nil), err
+	}
+	return rows[0], nil
+}
+
+func (qb *QueryBuilder) Exists() (bool, error) {
+	cons, _, err := qb.build()
+	if err != nil {
+		return false, err
+	}
+	rows, err := qb.conn().FindPageRows(qb.model, 1, 1, cons, nil)
+	return len(rows) > 0, err
+}
+
+func Eq(name string, value interface{}) Constraint {
+	return GenCons(name, value, CompareEqual)
+}
+
+func Ne(name string, value interface{}) Constraint {
+	return GenCons(name, value, CompareNotEqual)
+}
+
+func Lt(name string, value interface{}) Constraint {
+	return GenCons(name, value, CompareLessThan)
+}
+
+func Le(name string, value interface{}) Constraint {
+	return GenCons(name, value, CompareLessThanOrEqual)
+}
+
+func Gt(name string, value interface{}) Constraint {
+	return GenCons(name, value, CompareGreaterThan)
+}
+
+func Ge(name string, value interface{}) Constraint {
+	return GenCons(name, value, CompareGreaterThanOrEqual)
+}
+
+func Like(name string, value interface{}) Constraint {
+	return GenCons(name, value, CompareLike)
+}
+
+func NotLike(name string, value interface{}) Constraint {
+	return GenCons(name, value, CompareNotLike)
+}
+
+func ILike(name string, value interface{}) Constraint {
+	return GenCons(name, value, CompareILike)
+}
+
+func StartsWith(name string, value interface{}) Constraint {
+	return GenCons(name, value, CompareStartsWith)
+}
+
+func EndsWith(name string, value interface{}) Constraint {
+	return GenCons(name, value, CompareEndsWith)
+}
+
+func In(name string, values interface{}) Constraint {
+	return GenCons(name, values, CompareIn)
+}
+
+func NotIn(name string, values interface{}) Constraint {
+	return GenCons(name, values, CompareNotIn)
+}
+
+func IsNull(name string) Constraint {
+	return GenCons(name, nil, CompareIsNull)
+}
+
+func NotNull(name string) Constraint {
+	return GenCons(name, nil, CompareNotNull)
+}
+
+func Between(name string, begin interface{}, end interface{}) Constraint {
+	return GenBetween(name, begin, end)
+}
+
+func NotBetween(name string, begin interface{}, end interface{}) Constraint {
+	cons := GenBetween(name, begin, end)
+	cons.Compare = CompareNotBetween
+	return cons
+}
+
+func And(cons ...ConsWrapper) AndConstraint {
+	return AndConstraint{Wrapper: cons}
+}
+
+func Or(cons ...ConsWrapper) OrConstraint {
+	return OrConstraint{Wrapper: cons}
+}
+
+func Not(cons ConsWrapper) NotConstraint {
+	return GenNot(cons)
+}
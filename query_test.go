@@ -0,0 +1,38 @@
+package gorm
+
+import (
+	"gitops.sudytech.cn/guolei/gorm/driver"
+	"gitops.sudytech.cn/guolei/gorm/testdata"
+	"strings"
+	"testing"
+)
+
+func TestQueryBuilder_Build(t *testing.T) {
+	gm := &Gorm{Option: &Option{DbType: driver.DBTypeUxDB}}
+	cons, orders, err := gm.Query(&testdata.Algorithm{}).
+		Where(Eq("type", 1), Like("name", "张")).
+		Or(Eq("audit", 0), IsNull("audit")).
+		Where(Not(In("code", []string{"a", "b"}))).
+		Desc("createTime").
+		Asc("id").
+		Build()
+	if err != nil {
+		t.Fatalf("build.err=%v", err.GetMessage())
+	}
+	var build strings.Builder
+	params := GenDbWhereSQL(&build, driver.DBTypeUxDB, cons)
+	GenOrderSQL(&build, orders)
+	expect := ` AND ("type" = ? AND "name" LIKE ? ESCAPE '\' AND ("audit" = ? OR "audit" IS NULL) AND NOT ("code" IN (?,?))) ORDER BY "createTime" DESC,"id" ASC`
+	if build.String() != expect || len(params) != 5 {
+		t.Fatalf("sql=%s,params=%v,expect=%s", build.String(), params, expect)
+	}
+	if _, _, err = gm.Query(&testdata.Algorithm{}).Where(Eq("password", 1)).Build(); err == nil {
+		t.Fatalf("unknown column must be rejected")
+	}
+	if _, _, err = gm.Query(testdata.TableAlgorithm).Where(Eq("name", 1)).Build(); err == nil {
+		t.Fatalf("table name query without columns must be rejected")
+	}
+	if _, _, err = gm.Query(testdata.TableAlgorithm).Columns(AllowColumns("name")).Where(Eq("name", 1)).Build(); err != nil {
+		t.Fatalf("build.err=%v", err.GetMessage())
+	}
+}
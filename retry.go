@@ -0,0 +1,122 @@
+package gorm
+
+import (
+	"context"
+	"errors"
+	"go.uber.org/zap"
+	"math/rand"
+	"sync/atomic"
+	"time"
+)
+
+// RetryPolicy 事务重试策略，出现死锁、锁超时、序列化失败时重新执行整个事务，嵌套事务不重试
+type RetryPolicy struct {
+	MaxAttempts int                                               // 最多执行次数，小于2时不重试
+	BaseDelay   time.Duration                                     // 首次重试等待时间，默认50ms，之后按2倍递增
+	MaxDelay    time.Duration                                     // 最大等待时间，默认2s
+	Jitter      float64                                           // 等待时间随机浮动比例，0~1
+	Retryable   func(dbType int, err error) bool                  // 是否可重试，默认 IsRetryable
+	OnRetry     func(attempt int, delay time.Duration, err error) // 重试前回调，attempt为已执行次数
+}
+
+// RetryStats 重试统计
+type RetryStats struct {
+	Retries   int64 // 重试次数
+	Recovered int64 // 重试后成功的事务数
+	Exhausted int64 // 达到最多执行次数仍失败的事务数
+}
+
+var retryRetries, retryRecovered, retryExhausted int64
+
+func GetRetryStats() RetryStats {
+	return RetryStats{
+		Retries:   atomic.LoadInt64(&retryRetries),
+		Recovered: atomic.LoadInt64(&retryRecovered),
+		Exhausted: atomic.LoadInt64(&retryExhausted),
+	}
+}
+
+// WithRetry 返回使用重试策略的连接，作用于 TranSQL、TransactionSQL、Transaction
+func (gm *Gorm) WithRetry(policy RetryPolicy) *Gorm {
+	return &Gorm{DB: gm.DB, Option: gm.Option, retry: &policy, batch: gm.batch}
+}
+
+// IsRetryable 是否为锁冲突导致的可重试错误：MySQL 1213死锁、1205锁等待超时；优炫、海量 40001序列化失败、40P01死锁、55P03锁不可用；
+// 达梦 DmDeadlockCodes、DmLockTimeoutCodes，分类规则见 ClassifyError
+func IsRetryable(dbType int, err error) bool {
+	var dbErr *DbError
+	return errors.As(ClassifyError(dbType, err), &dbErr) && dbErr.Lock
+}
+
+// delay 第attempt次重试前的等待时间
+func (p *RetryPolicy) delay(attempt int) time.Duration {
+	base := p.BaseDelay
+	if base <= 0 {
+		base = 50 * time.Millisecond
+	}
+	max := p.MaxDelay
+	if max <= 0 {
+		max = 2 * time.Second
+	}
+	d := base
+	for i := 1; i < attempt && d < max; i++ {
+		d *= 2
+	}
+	if d > max {
+		d = max
+	}
+	if p.Jitter > 0 {
+		d += time.Duration((rand.Float64()*2 - 1) * p.Jitter * float64(d))
+	}
+	return d
+}
+
+// context 当前连接的上下文，未设置时为 context.Background()
+func (gm *Gorm) context() context.Context {
+	if ctx := gm.DB.Statement.Context; ctx != nil {
+		return ctx
+	}
+	return context.Background()
+}
+
+// withRetry 按重试策略执行fn，未设置策略或已在事务中时只执行一次
+func (gm *Gorm) withRetry(ctx context.Context, fn func() error) error {
+	p := gm.retry
+	if p == nil || p.MaxAttempts < 2 || gm.InTransaction() {
+		return fn()
+	}
+	retryable := p.Retryable
+	if retryable == nil {
+		retryable = IsRetryable
+	}
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if err == nil {
+			if attempt > 1 {
+				atomic.AddInt64(&retryRecovered, 1)
+			}
+			return nil
+		}
+		if !retryable(gm.Option.DbType, err) {
+			return err
+		}
+		if attempt >= p.MaxAttempts {
+			atomic.AddInt64(&retryExhausted, 1)
+			zap.S().Errorf("tran retry exhausted:attempts=%d,err=%v", attempt, err)
+			return err
+		}
+		d := p.delay(attempt)
+		atomic.AddInt64(&retryRetries, 1)
+		zap.S().Warnf("tran retry:attempt=%d,delay=%v,err=%v", attempt, d, err)
+		if p.OnRetry != nil {
+			p.OnRetry(attempt, d, err)
+		}
+		timer := time.NewTimer(d)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package gorm
+
+import (
+	"context"
+	sqldriver "database/sql/driver"
+	"errors"
+	"fmt"
+	"github.com/go-sql-driver/mysql"
+	"github.com/jackc/pgx/v5/pgconn"
+	"gitops.sudytech.cn/guolei/gorm/driver"
+	"gorm.io/gorm"
+	"testing"
+	"time"
+)
+
+type dmError struct {
+	ErrCode int32
+	ErrText string
+}
+
+func (e *dmError) Error() string {
+	return e.ErrText
+}
+
+func TestIsRetryable(t *testing.T) {
+	testdatas := []struct {
+		dbType int
+		err    error
+		expect bool
+	}{
+		{driver.DBTypeMySQL, &mysql.MySQLError{Number: 1213}, true},
+		{driver.DBTypeMySQL, fmt.Errorf("exec:%w", &mysql.MySQLError{Number: 1205}), true},
+		{driver.DBTypeMySQL, &mysql.MySQLError{Number: 1062}, false},
+		{driver.DBTypeUxDB, &pgconn.PgError{Code: "40001"}, true},
+		{driver.DBTypeVbDB, &pgconn.PgError{Code: "40P01"}, true},
+		{driver.DBTypeVbDB, &pgconn.PgError{Code: "23505"}, false},
+		{driver.DBTypeDmDB, &dmError{ErrCode: -6402}, true},
+		{driver.DBTypeDmDB, fmt.Errorf("exec:%w", &dmError{ErrCode: -6403}), true},
+		{driver.DBTypeDmDB, &dmError{ErrCode: -6602}, false},
+		{driver.DBTypeDmDB, errors.New("检测到死锁"), true},
+		{driver.DBTypeMySQL, errors.New("deadlock"), false},
+		{driver.DBTypeMySQL, nil, false},
+	}
+	for k, v := range testdatas {
+		if got := IsRetryable(v.dbType, v.err); got != v.expect {
+			t.Fatalf("%d.err=%v,retryable=%t", k, v.err, got)
+		}
+	}
+}
+
+func TestRetryPolicy_Delay(t *testing.T) {
+	p := &RetryPolicy{BaseDelay: 10 * time.Millisecond, MaxDelay: 50 * time.Millisecond}
+	for k, v := range []time.Duration{10, 20, 40, 50, 50} {
+		if d := p.delay(k + 1); d != v*time.Millisecond {
+			t.Fatalf("attempt=%d,delay=%v", k+1, d)
+		}
+	}
+	p.Jitter = 0.5
+	for i := 0; i < 100; i++ {
+		if d := p.delay(1); d < 5*time.Millisecond || d > 15*time.Millisecond {
+			t.Fatalf("jitter delay=%v", d)
+		}
+	}
+}
+
+func TestGorm_WithRetry(t *testing.T) {
+	gm := &Gorm{DB: &gorm.DB{Config: &gorm.Config{}, Statement: &gorm.Statement{}}, Option: &Option{}}
+	var retries []int
+	rgm := gm.WithRetry(RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond, OnRetry: func(attempt int, delay time.Duration, err error) {
+		retries = append(retries, attempt)
+	}})
+	calls := 0
+	err := rgm.withRetry(context.Background(), func() error {
+		calls++
+		if calls < 3 {
+			return &mysql.MySQLError{Number: 1213}
+		}
+		return nil
+	})
+	if err != nil || calls != 3 || len(retries) != 2 {
+		t.Fatalf("calls=%d,retries=%v,err=%v", calls, retries, err)
+	}
+	calls = 0
+	err = rgm.withRetry(context.Background(), func() error {
+		calls++
+		return &mysql.MySQLError{Number: 1062}
+	})
+	if err == nil || calls != 1 {
+		t.Fatalf("non retryable error must not be retried,calls=%d", calls)
+	}
+	calls = 0
+	if err = gm.withRetry(context.Background(), func() error {
+		calls++
+		return &mysql.MySQLError{Number: 1213}
+	}); err == nil || calls != 1 {
+		t.Fatalf("no policy must not retry,calls=%d", calls)
+	}
+	if stats := GetRetryStats(); stats.Retries < 2 || stats.Recovered < 1 {
+		t.Fatalf("stats=%+v", stats)
+	}
+}
+
+func TestGorm_TranSQLRetryContext(t *testing.T) {
+	gm, c := fakeGorm(t, func(query string, args []sqldriver.Value) (*fakeResult, error) {
+		return nil, &mysql.MySQLError{Number: 1213}
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	rgm := gm.WithRetry(RetryPolicy{MaxAttempts: 3, BaseDelay: time.Hour, OnRetry: func(attempt int, delay time.Duration, err error) {
+		cancel()
+	}}).WithContext(ctx)
+	// 等待时间为1小时，未使用连接上下文时会阻塞
+	err := rgm.TranSQL([]TranSQL{{SQL: "UPDATE T_TEST SET a=1"}})
+	if err == nil {
+		t.Fatalf("err=%v", err)
+	}
+	if stmts := c.statements(); len(stmts) != 3 || stmts[2] != "ROLLBACK" {
+		t.Fatalf("canceled context must stop retry,stmts=%v", stmts)
+	}
+}
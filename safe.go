@@ -0,0 +1,204 @@
+package gorm
+
+import (
+	"fmt"
+	"gitops.sudytech.cn/guolei/gorm/driver"
+	"gitops.sudytech.cn/guolei/gorm/sys"
+	"strings"
+)
+
+// ColumnSet 查询字段白名单，key为小写的属性名、json名或字段名，value为数据库字段
+type ColumnSet struct {
+	alias   string
+	columns map[string]string
+}
+
+// ModelColumns 根据模型gorm标签生成白名单，支持属性名、json名及字段名
+func ModelColumns(model interface{}) *ColumnSet {
+	columns := make(map[string]string)
+	for _, tag := range sys.GetTags(model) {
+		if g := tag.GormP; g != nil && g.Column != "" {
+			columns[strings.ToLower(g.Column)] = g.Column
+			columns[strings.ToLower(tag.Name)] = g.Column
+			if tag.Json != "" {
+				columns[strings.ToLower(tag.Json)] = g.Column
+			}
+		}
+	}
+	return &ColumnSet{columns: columns}
+}
+
+// AllowColumns 显式指定允许的数据库字段
+func AllowColumns(columns ...string) *ColumnSet {
+	cs := &ColumnSet{columns: make(map[string]string)}
+	for _, v := range columns {
+		cs.columns[strings.ToLower(v)] = v
+	}
+	return cs
+}
+
+// MapColumns 显式指定名称到数据库字段的映射，如json名到字段
+func MapColumns(mapping map[string]string) *ColumnSet {
+	cs := &ColumnSet{columns: make(map[string]string)}
+	for k, v := range mapping {
+		cs.columns[strings.ToLower(k)] = v
+		cs.columns[strings.ToLower(v)] = v
+	}
+	return cs
+}
+
+// Alias 返回带表别名的白名单，生成的字段为 alias.column
+func (cs *ColumnSet) Alias(alias string) *ColumnSet {
+	return &ColumnSet{alias: alias, columns: cs.columns}
+}
+
+// Column 返回名称对应的数据库字段，允许使用 alias.name 形式
+func (cs *ColumnSet) Column(name string) (string, bool) {
+	name = strings.TrimSpace(name)
+	if i := strings.Index(name, "."); i != -1 {
+		if cs.alias == "" || !strings.EqualFold(name[0:i], cs.alias) {
+			return "", false
+		}
+		name = name[i+1:]
+	}
+	column, ok := cs.columns[strings.ToLower(name)]
+	return column, ok
+}
+
+// columnQuoter 将查询名称解析为加引号的数据库字段
+type columnQuoter interface {
+	quote(exp driver.Exp, name string) (string, sys.IGormErr)
+}
+
+func (cs *ColumnSet) quote(exp driver.Exp, name string) (string, sys.IGormErr) {
+	column, ok := cs.Column(name)
+	if !ok {
+		return "", sys.NewMessage(sys.PropNotAllowCode, fmt.Sprintf("%s不在允许的字段范围内", name))
+	}
+	if cs.alias != "" {
+		column = cs.alias + "." + column
+	}
+	return exp.Quote(column), nil
+}
+
+// SafeCons 校验条件字段是否在白名单内，并替换为加引号的数据库字段
+func (opt *Option) SafeCons(cs *ColumnSet, cons []ConsWrapper) ([]ConsWrapper, sys.IGormErr) {
+	return safeConsList(driver.Exp{DbType: opt.DbType, Schema: opt.Schema}, cs, cons)
+}
+
+func safeConsList(exp driver.Exp, cs columnQuoter, cons []ConsWrapper) ([]ConsWrapper, sys.IGormErr) {
+	if err := CheckCons(cons); err != nil {
+		return nil, sys.NewMessage(sys.PropNotAllowCode, err.Error())
+	}
+	var resp []ConsWrapper
+	for _, v := range cons {
+		wp, err := safeCons(exp, cs, v)
+		if err != nil {
+			return nil, err
+		}
+		resp = append(resp, wp)
+	}
+	return resp, nil
+}
+
+func safeCons(exp driver.Exp, cs columnQuoter, wp ConsWrapper) (ConsWrapper, sys.IGormErr) {
+	if wp.IsCons() {
+		cons := wp.AsConstraint()
+		switch cons.Compare {
+		case CompareExists, CompareNotExists, CompareRaw:
+			return nil, sys.NewMessage(sys.PropNotAllowCode, fmt.Sprintf("%s不允许用于白名单查询", GetSymbol(cons.Compare)))
+		}
+		name, err := cs.quote(exp, cons.Name)
+		if err != nil {
+			return nil, err
+		}
+		return Constraint{Compare: cons.Compare, Name: name, Value: cons.Value}, nil
+	} else if wp.IsOr() {
+		var wrapper []ConsWrapper
+		if or := wp.AsOrConstraint(); or != nil {
+			for _, v := range or.Wrapper {
+				nwp, err := safeCons(exp, cs, v)
+				if err != nil {
+					return nil, err
+				}
+				wrapper = append(wrapper, nwp)
+			}
+		}
+		return OrConstraint{Wrapper: wrapper}, nil
+	} else if wp.IsAnd() {
+		var wrapper []ConsWrapper
+		if and := wp.AsAndConstraint(); and != nil {
+			for _, v := range and.Wrapper {
+				nwp, err := safeCons(exp, cs, v)
+				if err != nil {
+					return nil, err
+				}
+				wrapper = append(wrapper, nwp)
+			}
+		}
+		return AndConstraint{Wrapper: wrapper}, nil
+	} else if wp.IsNot() {
+		if not := wp.AsNotConstraint(); not != nil && not.Wrapper != nil {
+			nwp, err := safeCons(exp, cs, not.Wrapper)
+			if err != nil {
+				return nil, err
+			}
+			return NotConstraint{Wrapper: nwp}, nil
+		}
+		return NotConstraint{}, nil
+	}
+	return nil, sys.NewMessage(sys.PropNotAllowCode, "不支持的查询条件")
+}
+
+// SafeOrders 校验排序字段是否在白名单内，并替换为加引号的数据库字段
+func (opt *Option) SafeOrders(cs *ColumnSet, orders []QueryOrder) ([]QueryOrder, sys.IGormErr) {
+	return safeOrders(driver.Exp{DbType: opt.DbType, Schema: opt.Schema}, cs, orders)
+}
+
+func safeOrders(exp driver.Exp, cs columnQuoter, orders []QueryOrder) ([]QueryOrder, sys.IGormErr) {
+	var resp []QueryOrder
+	for _, v := range orders {
+		name, err := cs.quote(exp, v.FieldName)
+		if err != nil {
+			return nil, err
+		}
+		resp = append(resp, QueryOrder{Asc: v.Asc, FieldName: name})
+	}
+	return resp, nil
+}
+
+// SafeWrapper 使用模型字段白名单校验查询条件和排序
+func (opt *Option) SafeWrapper(model interface{}, cons []ConsWrapper, orders []QueryOrder) ([]ConsWrapper, []QueryOrder, sys.IGormErr) {
+	cs := ModelColumns(model)
+	nCons, err := opt.SafeCons(cs, cons)
+	if err != nil {
+		return nil, nil, err
+	}
+	nOrders, err := opt.SafeOrders(cs, orders)
+	if err != nil {
+		return nil, nil, err
+	}
+	return nCons, nOrders, nil
+}
+
+func (gm *Gorm) SafeCons(cs *ColumnSet, cons []ConsWrapper) ([]ConsWrapper, sys.IGormErr) {
+	return gm.Option.SafeCons(cs, cons)
+}
+
+func (gm *Gorm) SafeOrders(cs *ColumnSet, orders []QueryOrder) ([]QueryOrder, sys.IGormErr) {
+	return gm.Option.SafeOrders(cs, orders)
+}
+
+func (gm *Gorm) SafeWrapper(model interface{}, cons []ConsWrapper, orders []QueryOrder) ([]ConsWrapper, []QueryOrder, sys.IGormErr) {
+	return gm.Option.SafeWrapper(model, cons, orders)
+}
+
+// FindSafePageList 字段白名单校验后分页查询，非法字段返回 sys.PropNotAllowCode
+func (gm *Gorm) FindSafePageList(model interface{}, pageNo int32, pageSize int32, cons []ConsWrapper, orders []QueryOrder) ([]Row, int64, sys.IGormErr) {
+	nCons, nOrders, err := gm.SafeWrapper(model, cons, orders)
+	if err != nil {
+		return nil, 0, err
+	}
+	resp, count, fErr := gm.FindPageList(model, pageNo, pageSize, nCons, nOrders)
+	return resp, count, sys.ErrIF(fErr)
+}
@@ -0,0 +1,43 @@
+package gorm
+
+import (
+	"gitops.sudytech.cn/guolei/gorm/driver"
+	"gitops.sudytech.cn/guolei/gorm/sys"
+	"gitops.sudytech.cn/guolei/gorm/testdata"
+	"strings"
+	"testing"
+)
+
+func TestOption_SafeWrapper(t *testing.T) {
+	opts := []*Option{{DbType: driver.DBTypeMySQL}, {DbType: driver.DBTypeUxDB}}
+	expects := []string{" AND (`name` = ? AND `updateTime` >= ?) ORDER BY `createTime` DESC", " AND (\"name\" = ? AND \"updateTime\" >= ?) ORDER BY \"createTime\" DESC"}
+	cons := []ConsWrapper{GenCons("Name", "a", CompareEqual), GenCons("updateTime", "2024-01-01", CompareGreaterThanOrEqual)}
+	orders := []QueryOrder{{FieldName: "CreateTime"}}
+	for k, v := range opts {
+		nCons, nOrders, err := v.SafeWrapper(&testdata.Algorithm{}, cons, orders)
+		if err != nil {
+			t.Fatalf("%s.safe.err=%v", driver.GetDbName(v.DbType), err.GetMessage())
+		}
+		var build strings.Builder
+		GenWhereSQL(&build, nCons)
+		GenOrderSQL(&build, nOrders)
+		if build.String() != expects[k] {
+			t.Fatalf("%s.sql=%s,expect=%s", driver.GetDbName(v.DbType), build.String(), expects[k])
+		}
+	}
+}
+
+func TestOption_SafeCons(t *testing.T) {
+	opt := &Option{DbType: driver.DBTypeMySQL}
+	cons := []ConsWrapper{OrConstraint{Wrapper: []ConsWrapper{GenCons("name", "a", CompareEqual), GenCons("name;DROP TABLE x", "b", CompareEqual)}}}
+	if _, err := opt.SafeCons(ModelColumns(&testdata.Algorithm{}), cons); err == nil || err.GetCode() != sys.PropNotAllowCode {
+		t.Fatalf("illegal column must be rejected")
+	}
+	if _, err := opt.SafeOrders(AllowColumns("code"), []QueryOrder{{FieldName: "name"}}); err == nil {
+		t.Fatalf("column out of allowed set must be rejected")
+	}
+	nCons, err := opt.SafeCons(MapColumns(map[string]string{"orgName": "name"}).Alias("o"), []ConsWrapper{GenCons("o.orgName", "a", CompareEqual)})
+	if err != nil || nCons[0].AsConstraint().Name != "`o`.`name`" {
+		t.Fatalf("alias column=%v,err=%v", nCons, err)
+	}
+}
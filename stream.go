@@ -0,0 +1,241 @@
+package gorm
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"gitops.sudytech.cn/guolei/gorm/driver"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	"sync/atomic"
+)
+
+// ErrStopIteration QueryEach回调返回该错误时停止遍历，QueryEach返回nil
+var ErrStopIteration = errors.New("stop iteration")
+
+// IterOptions 流式查询选项
+type IterOptions struct {
+	// FetchSize 每批获取的记录数，0为由驱动逐行读取；优炫、海量使用服务端游标(DECLARE/FETCH)，
+	// 达梦在同一只读事务中按 LIMIT/OFFSET 分批查询，SQL需有顶层ORDER BY且排序唯一，不能有LIMIT；MySQL驱动本身逐行读取，忽略该选项
+	FetchSize int
+}
+
+var cursorSeq int64
+
+// RowIter 基于 *sql.Rows 的记录迭代器，逐行扫描为Row，使用后必须Close
+//
+//	it, err := gm.QueryIter(ctx, IterOptions{}, sql, params...)
+//	if err != nil {
+//		return err
+//	}
+//	defer it.Close()
+//	for it.Next() {
+//		row := it.Row()
+//	}
+//	return it.Err()
+type RowIter struct {
+	db     *gorm.DB
+	rows   *sql.Rows
+	fetch  func() (*sql.Rows, error) // 服务端游标获取下一批，为nil时只有一批
+	done   func(err error) error     // 关闭游标及事务
+	count  int
+	row    Row
+	err    error
+	closed bool
+}
+
+// QueryIter 流式查询，SQL处理同 QueryRow，ctx中有事务时在该事务中查询
+func (gm *Gorm) QueryIter(ctx context.Context, opts IterOptions, sql string, params ...interface{}) (*RowIter, error) {
+	gm = gm.WithContext(ctx)
+	opt := gm.Option
+	exp := driver.Exp{DbType: opt.DbType, Schema: opt.Schema}
+	nSQL := exp.QuerySQL(sql)
+	switch opt.DbType {
+	case driver.DBTypeUxDB, driver.DBTypeVbDB:
+		if opts.FetchSize > 0 {
+			it, err := gm.cursorIter(opts.FetchSize, nSQL, params)
+			if err != nil {
+				zap.S().Errorf("query iter:src=%s,new=%s,param=%v,err=%v", sql, nSQL, params, err)
+			}
+			return it, err
+		}
+	case driver.DBTypeDmDB:
+		if opts.FetchSize > 0 {
+			it, err := gm.pageIter(opts.FetchSize, nSQL, params)
+			if err != nil {
+				zap.S().Errorf("query iter:src=%s,new=%s,param=%v,err=%v", sql, nSQL, params, err)
+			}
+			return it, err
+		}
+	}
+	rows, err := gm.DB.Raw(nSQL, params...).Rows()
+	if err != nil {
+		zap.S().Errorf("query iter:src=%s,new=%s,param=%v,err=%v", sql, nSQL, params, err)
+		return nil, err
+	}
+	return &RowIter{db: gm.DB, rows: rows}, nil
+}
+
+// cursorIter 声明服务端游标按批FETCH，结束时关闭游标
+func (gm *Gorm) cursorIter(fetchSize int, nSQL string, params []interface{}) (*RowIter, error) {
+	return gm.iterInTx(func(tx *gorm.DB) (*RowIter, error) {
+		name := fmt.Sprintf("gorm_iter_%d", atomic.AddInt64(&cursorSeq, 1))
+		if err := tx.Exec(fmt.Sprintf("DECLARE %s NO SCROLL CURSOR FOR %s", name, nSQL), params...).Error; err != nil {
+			return nil, err
+		}
+		fetch := func() (*sql.Rows, error) {
+			return tx.Raw(fmt.Sprintf("FETCH FORWARD %d FROM %s", fetchSize, name)).Rows()
+		}
+		rows, err := fetch()
+		if err != nil {
+			return nil, err
+		}
+		return &RowIter{db: tx, rows: rows, fetch: fetch, done: func(err error) error {
+			if err != nil {
+				return err
+			}
+			return tx.Exec("CLOSE " + name).Error
+		}}, nil
+	})
+}
+
+// pageIter 按 LIMIT/OFFSET 分批查询，依赖SQL中唯一的ORDER BY保证各批记录不重复、不遗漏
+func (gm *Gorm) pageIter(fetchSize int, nSQL string, params []interface{}) (*RowIter, error) {
+	ordered, limited := false, false
+	scanTopWords(nSQL, func(word string, _ int) bool {
+		switch word {
+		case "ORDER":
+			ordered = true
+		case "LIMIT", "OFFSET", "FETCH", "TOP":
+			limited = true
+		}
+		return true
+	})
+	if !ordered || limited {
+		return nil, errors.New("达梦按FetchSize分批查询需要顶层ORDER BY且不能有LIMIT")
+	}
+	return gm.iterInTx(func(tx *gorm.DB) (*RowIter, error) {
+		offset := 0
+		fetch := func() (*sql.Rows, error) {
+			rows, err := tx.Raw(fmt.Sprintf("%s LIMIT %d OFFSET %d", nSQL, fetchSize, offset), params...).Rows()
+			offset += fetchSize
+			return rows, err
+		}
+		rows, err := fetch()
+		if err != nil {
+			return nil, err
+		}
+		return &RowIter{db: tx, rows: rows, fetch: fetch}, nil
+	})
+}
+
+// iterInTx 在事务中创建迭代器，已在事务中时使用该事务，否则开启只读事务并在迭代器关闭时提交
+func (gm *Gorm) iterInTx(open func(tx *gorm.DB) (*RowIter, error)) (*RowIter, error) {
+	if gm.InTransaction() {
+		return open(gm.DB)
+	}
+	tx := gm.DB.Begin(&sql.TxOptions{ReadOnly: true})
+	if tx.Error != nil {
+		return nil, tx.Error
+	}
+	it, err := open(tx)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	done := it.done
+	it.done = func(err error) error {
+		if done != nil {
+			err = done(err)
+		}
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+		return tx.Commit().Error
+	}
+	return it, nil
+}
+
+// Next 读取下一条记录，没有记录或出错时返回false
+func (it *RowIter) Next() bool {
+	for !it.closed && it.err == nil {
+		if it.rows.Next() {
+			var data map[string]interface{}
+			if it.err = it.db.ScanRows(it.rows, &data); it.err != nil {
+				return false
+			}
+			it.row = NewRow(data)
+			it.count++
+			return true
+		}
+		if it.err = it.rows.Err(); it.err != nil || it.fetch == nil || it.count == 0 {
+			return false
+		}
+		it.count = 0
+		if it.err = it.rows.Close(); it.err != nil {
+			return false
+		}
+		it.rows, it.err = it.fetch()
+	}
+	return false
+}
+
+// Columns 结果集字段，顺序与查询一致
+func (it *RowIter) Columns() ([]string, error) {
+	return it.rows.Columns()
+}
+
+// Row 当前记录
+func (it *RowIter) Row() Row {
+	return it.row
+}
+
+func (it *RowIter) Err() error {
+	return it.err
+}
+
+// Close 关闭结果集，可重复调用，提前结束遍历时同样需要调用
+func (it *RowIter) Close() error {
+	if it.closed {
+		return nil
+	}
+	it.closed = true
+	var err error
+	if it.rows != nil {
+		err = it.rows.Close()
+	}
+	if it.done != nil {
+		if dErr := it.done(it.err); err == nil {
+			err = dErr
+		}
+	}
+	return err
+}
+
+// QueryEach 逐行遍历查询结果，fn返回错误时停止遍历并返回该错误，返回 ErrStopIteration 时正常结束
+func (gm *Gorm) QueryEach(ctx context.Context, sql string, params []interface{}, fn func(Row) error, opts ...IterOptions) error {
+	var opt IterOptions
+	if len(opts) > 0 {
+		opt = opts[0]
+	}
+	it, err := gm.QueryIter(ctx, opt, sql, params...)
+	if err != nil {
+		return err
+	}
+	for it.Next() {
+		if err = fn(it.Row()); err != nil {
+			it.Close()
+			if errors.Is(err, ErrStopIteration) {
+				return nil
+			}
+			return err
+		}
+	}
+	if err = it.Err(); err != nil {
+		it.Close()
+		return err
+	}
+	return it.Close()
+}
@@ -0,0 +1,114 @@
+package gorm
+
+import (
+	"context"
+	sqldriver "database/sql/driver"
+	"errors"
+	"fmt"
+	"gitops.sudytech.cn/guolei/gorm/driver"
+	"strings"
+	"testing"
+)
+
+func TestGorm_QueryEachFake(t *testing.T) {
+	gm, _ := fakeGorm(t, func(query string, args []sqldriver.Value) (*fakeResult, error) {
+		return &fakeResult{columns: []string{"id", "name"}, rows: [][]sqldriver.Value{{int64(1), "a"}, {int64(2), "b"}, {int64(3), "c"}}}, nil
+	})
+	var names []string
+	err := gm.QueryEach(context.Background(), "SELECT id,name FROM T_TEST", nil, func(row Row) error {
+		names = append(names, row.GetString("name"))
+		return nil
+	})
+	if err != nil || strings.Join(names, ",") != "a,b,c" {
+		t.Fatalf("names=%v,err=%v", names, err)
+	}
+	names = nil
+	err = gm.QueryEach(context.Background(), "SELECT id,name FROM T_TEST", nil, func(row Row) error {
+		if names = append(names, row.GetString("name")); len(names) == 2 {
+			return ErrStopIteration
+		}
+		return nil
+	})
+	if err != nil || len(names) != 2 {
+		t.Fatalf("stop:names=%v,err=%v", names, err)
+	}
+	errFn := errors.New("fn")
+	if err = gm.QueryEach(context.Background(), "SELECT id,name FROM T_TEST", nil, func(row Row) error {
+		return errFn
+	}); err != errFn {
+		t.Fatalf("fn err=%v", err)
+	}
+	it, err := gm.QueryIter(context.Background(), IterOptions{}, "SELECT id,name FROM T_TEST")
+	if err != nil {
+		t.Fatalf("iter err=%v", err)
+	}
+	if cols, cErr := it.Columns(); cErr != nil || strings.Join(cols, ",") != "id,name" {
+		t.Fatalf("cols=%v,err=%v", cols, cErr)
+	}
+	if !it.Next() || it.Row().GetInt64("id") != 1 {
+		t.Fatalf("row=%v,err=%v", it.Row(), it.Err())
+	}
+	if it.Close() != nil || it.Close() != nil || it.Next() {
+		t.Fatalf("closed iterator")
+	}
+	gm.Option.DbType = driver.DBTypeDmDB
+	for _, v := range []string{"SELECT id,name FROM T_TEST", "SELECT id FROM T_TEST ORDER BY id LIMIT 10", "SELECT id FROM (SELECT id FROM T_TEST ORDER BY id) t"} {
+		if _, err = gm.QueryIter(context.Background(), IterOptions{FetchSize: 10}, v); err == nil {
+			t.Fatalf("dm paging without order must fail,sql=%s", v)
+		}
+	}
+}
+
+func TestGorm_QueryEachDmPage(t *testing.T) {
+	var ids []int64
+	gm, c := fakeGorm(t, func(query string, args []sqldriver.Value) (*fakeResult, error) {
+		var offset int
+		if _, err := fmt.Sscanf(query[strings.Index(query, "OFFSET"):], "OFFSET %d", &offset); err != nil {
+			return nil, nil
+		}
+		var rows [][]sqldriver.Value
+		for i := offset; i < offset+2 && i < 3; i++ {
+			rows = append(rows, []sqldriver.Value{int64(i + 1)})
+		}
+		return &fakeResult{columns: []string{"id"}, rows: rows}, nil
+	})
+	gm.Option.DbType = driver.DBTypeDmDB
+	err := gm.QueryEach(context.Background(), "SELECT id FROM T_TEST WHERE type=? ORDER BY id", []interface{}{1}, func(row Row) error {
+		ids = append(ids, row.GetInt64("id"))
+		return nil
+	}, IterOptions{FetchSize: 2})
+	if err != nil || len(ids) != 3 || ids[2] != 3 {
+		t.Fatalf("ids=%v,err=%v", ids, err)
+	}
+	stmts := c.statements()
+	if len(stmts) != 5 || stmts[0] != "BEGIN" || !strings.HasSuffix(stmts[1], "ORDER BY id LIMIT 2 OFFSET 0") ||
+		!strings.HasSuffix(stmts[3], "LIMIT 2 OFFSET 4") || stmts[4] != "COMMIT" {
+		t.Fatalf("stmts=%v", stmts)
+	}
+}
+
+func TestGorm_QueryEachCursor(t *testing.T) {
+	batches := [][][]sqldriver.Value{{{int64(1)}, {int64(2)}}, {{int64(3)}}, {}}
+	gm, c := fakeGorm(t, func(query string, args []sqldriver.Value) (*fakeResult, error) {
+		if !strings.HasPrefix(query, "FETCH FORWARD 2 FROM ") {
+			return nil, nil
+		}
+		rows := batches[0]
+		batches = batches[1:]
+		return &fakeResult{columns: []string{"id"}, rows: rows}, nil
+	})
+	gm.Option.DbType = driver.DBTypeUxDB
+	var ids []int64
+	err := gm.QueryEach(context.Background(), "SELECT id FROM T_TEST", nil, func(row Row) error {
+		ids = append(ids, row.GetInt64("id"))
+		return nil
+	}, IterOptions{FetchSize: 2})
+	if err != nil || len(ids) != 3 || ids[2] != 3 {
+		t.Fatalf("ids=%v,err=%v", ids, err)
+	}
+	stmts := c.statements()
+	if len(stmts) != 7 || stmts[0] != "BEGIN" || !strings.HasPrefix(stmts[1], "DECLARE gorm_iter_") ||
+		!strings.HasPrefix(stmts[5], "CLOSE gorm_iter_") || stmts[6] != "COMMIT" {
+		t.Fatalf("stmts=%v", stmts)
+	}
+}
@@ -11,6 +11,7 @@ import (
 
 type Prop struct {
 	Name  string
+	Json  string
 	GormP *GormP
 	SormP *SormP
 }
@@ -22,6 +23,7 @@ type GormP struct {
 	AutoInc  bool   //自增
 	Required bool   //必须
 	Default  string //默认值
+	Unique   string //唯一索引名，unique或未指定名称的uniqueIndex为字段名
 }
 
 type SormP struct {
@@ -38,11 +40,17 @@ type SormP struct {
 
 func toGormP(tag string) *GormP {
 	gorm := &GormP{}
+	unique := false
 	tags := strings.Split(tag, ";")
 	for _, v := range tags {
 		vs := strings.Split(v, ":")
+		if vs[0] == "uniqueIndex" && len(vs) > 1 {
+			gorm.Unique = strings.Split(vs[1], ",")[0]
+		}
 		if len(vs) == 1 {
 			switch vs[0] {
+			case "unique", "uniqueIndex":
+				unique = true
 			case "autoIncrement":
 				gorm.AutoInc = true
 			case "not null":
@@ -70,6 +78,9 @@ func toGormP(tag string) *GormP {
 			}
 		}
 	}
+	if unique && gorm.Unique == "" {
+		gorm.Unique = gorm.Column
+	}
 	return lo.Ternary(gorm.Column == "", nil, gorm)
 }
 
@@ -84,6 +95,20 @@ func toArray(req string) []string {
 	return result
 }
 
+// EnumLabels 枚举值对应的显示名称，枚举格式为 enumeration:0=禁用,1=启用，未指定名称的枚举值不返回
+func (s *SormP) EnumLabels() map[string]string {
+	labels := make(map[string]string)
+	if s == nil {
+		return labels
+	}
+	for _, v := range s.Enumeration {
+		if i := strings.Index(v, "="); i != -1 {
+			labels[v[0:i]] = v[i+1:]
+		}
+	}
+	return labels
+}
+
 func toSormP(tag string) *SormP {
 	gorm := &SormP{}
 	tags := strings.Split(tag, ";")
@@ -174,14 +199,21 @@ func GetTag(model interface{}, name string) (Prop, bool) {
 	field := v.Name
 	gorm := toGormP(v.Tag.Get("gorm"))
 	sorm := toSormP(v.Tag.Get("sorm"))
-	return Prop{Name: field, GormP: gorm, SormP: sorm}, true
+	return Prop{Name: field, Json: toJson(v.Tag.Get("json")), GormP: gorm, SormP: sorm}, true
 }
 
 func GetTagByField(field reflect.StructField) Prop {
 	name := field.Name
 	gorm := toGormP(field.Tag.Get("gorm"))
 	sorm := toSormP(field.Tag.Get("sorm"))
-	return Prop{Name: name, GormP: gorm, SormP: sorm}
+	return Prop{Name: name, Json: toJson(field.Tag.Get("json")), GormP: gorm, SormP: sorm}
+}
+
+func toJson(tag string) string {
+	if i := strings.Index(tag, ","); i != -1 {
+		tag = tag[0:i]
+	}
+	return lo.Ternary(tag == "-", "", tag)
 }
 
 func CheckRequire(value interface{}, check Prop) IGormErr {
@@ -27,12 +27,12 @@ func TestCheckBaseInfo(t *testing.T) {
 	value := make(map[string]interface{})
 	value["name"] = "11"
 	alg := &testdata.Algorithm{}
-	fmt.Println(CheckBaseInfo(alg, value))
+	fmt.Println(VerifyCreateInfo(alg, value))
 }
 
 func TestCheckDataInfo(t *testing.T) {
 	value := make(map[string]interface{})
 	value["name"] = "11"
 	alg := &testdata.Algorithm{}
-	fmt.Println(CheckDataInfo(alg, value))
+	fmt.Println(VerifyUpdateInfo(alg, value))
 }
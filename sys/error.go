@@ -9,8 +9,16 @@ const (
 	PropNoExistCode  = 4100 //属性值为空或不存在
 	PropNoNumberCode = 4110 //属性值非数字类型
 	PropErrorCode    = 4120 //属性值错误，超过属性值范围
+	PropNotAllowCode = 4130 //属性不在允许范围内
 	NoPermitCode     = 3500 //无权限操作
 	MarkDeleteCode   = 3002 //被标记删除
+	DuplicateCode    = 4200 //唯一约束冲突
+	ForeignKeyCode   = 4210 //外键约束冲突
+	NotNullCode      = 4220 //非空约束冲突
+	CheckCode        = 4230 //检查约束冲突
+	DeadlockCode     = 5100 //死锁或序列化失败
+	TimeoutCode      = 5110 //锁等待或执行超时
+	ConnectionCode   = 5200 //数据库连接失败
 )
 
 type GormErr struct {
@@ -48,7 +56,15 @@ func ErrIF(err error) IGormErr {
 	}
 }
 
+// NewErr 转换为 IGormErr，err本身或包装的错误实现 IGormErr 时使用其错误码，否则为 FailCode
 func NewErr(err error) IGormErr {
+	var gErr IGormErr
+	if errors.As(err, &gErr) {
+		if ge, ok := err.(IGormErr); ok {
+			return ge
+		}
+		return NewError(gErr.GetCode(), err)
+	}
 	return NewError(FailCode, err)
 }
 
@@ -0,0 +1,29 @@
+package sys
+
+import "testing"
+
+func TestSormP_EnumLabels(t *testing.T) {
+	labels := toSormP("enumeration:0=禁用,1=启用,2").EnumLabels()
+	if len(labels) != 2 || labels["0"] != "禁用" || labels["1"] != "启用" {
+		t.Fatalf("labels=%v", labels)
+	}
+	var p *SormP
+	if len(p.EnumLabels()) != 0 {
+		t.Fatalf("nil sorm must have no labels")
+	}
+}
+
+func TestToGormP_Unique(t *testing.T) {
+	testdatas := map[string]string{
+		"column:code;type:varchar(40);uniqueIndex:idx_code;": "idx_code",
+		"column:code;uniqueIndex:idx_org_code,priority:2;":   "idx_org_code",
+		"uniqueIndex;column:code":                            "code",
+		"column:code;unique":                                 "code",
+		"column:code;index:idx_code":                         "",
+	}
+	for k, v := range testdatas {
+		if g := toGormP(k); g.Unique != v {
+			t.Fatalf("tag=%s,unique=%s,expect=%s", k, g.Unique, v)
+		}
+	}
+}
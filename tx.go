@@ -0,0 +1,120 @@
+package gorm
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	"sync/atomic"
+)
+
+var savepointSeq int64
+
+// WithTx 返回使用指定事务的连接，其中的 TranSQL、TransactionSQL 以保存点方式嵌套执行
+//
+//	gm.TransactionSQL(sqls, func(tx *gorm.DB, exp driver.Exp) error {
+//		// 内层出错时只回滚到保存点，外层可以忽略该错误继续提交
+//		if err := gm.WithTx(tx).TransactionSQL(inner); err != nil {
+//			zap.S().Warn(err)
+//		}
+//		return nil
+//	})
+func (gm *Gorm) WithTx(tx *gorm.DB) *Gorm {
+	return &Gorm{DB: tx, Option: gm.Option, retry: gm.retry, batch: gm.batch}
+}
+
+// InTransaction 当前连接是否处于事务中
+func (gm *Gorm) InTransaction() bool {
+	committer, ok := gm.DB.Statement.ConnPool.(gorm.TxCommitter)
+	return ok && committer != nil
+}
+
+// transaction 不在事务中时开启事务，已在事务中时创建保存点，出错或panic时只回滚到该保存点
+func (gm *Gorm) transaction(fc func(tx *gorm.DB) error) (err error) {
+	if !gm.InTransaction() {
+		return gm.DB.Transaction(fc)
+	}
+	sp, ok := gm.DB.Dialector.(gorm.SavePointerDialectorInterface)
+	if !ok {
+		return gorm.ErrUnsupportedDriver
+	}
+	name := fmt.Sprintf("gorm_sp_%d", atomic.AddInt64(&savepointSeq, 1))
+	if err = sp.SavePoint(gm.DB.Session(&gorm.Session{NewDB: true}), name); err != nil {
+		zap.S().Errorf("savepoint:name=%s,err=%v", name, err)
+		return fmt.Errorf("savepoint %s:%w", name, err)
+	}
+	panicked := true
+	defer func() {
+		if panicked || err != nil {
+			if rErr := sp.RollbackTo(gm.DB.Session(&gorm.Session{NewDB: true}), name); rErr != nil {
+				zap.S().Errorf("rollback to savepoint:name=%s,err=%v", name, rErr)
+				if err == nil {
+					err = fmt.Errorf("rollback to savepoint %s:%w", name, rErr)
+				} else {
+					err = fmt.Errorf("%w;rollback to savepoint %s:%v", err, name, rErr)
+				}
+			}
+		}
+	}()
+	err = fc(gm.DB.Session(&gorm.Session{NewDB: true}))
+	panicked = false
+	return err
+}
+
+type txKey struct{}
+
+// txContext 上下文中的事务，opt用于区分不同的数据库连接
+type txContext struct {
+	opt *Option
+	tx  *gorm.DB
+}
+
+// TxOptions 事务选项，Isolation为0时使用数据库默认隔离级别
+type TxOptions struct {
+	Isolation sql.IsolationLevel
+	ReadOnly  bool
+}
+
+// Transaction 在事务中执行fn，fn中通过 WithContext(ctx) 获取的连接加入该事务，SQL转换规则不变；
+// ctx中已有该连接的事务时以保存点嵌套执行，此时opts不生效
+//
+//	err := gm.Transaction(ctx, func(ctx context.Context) error {
+//		if err := gm.WithContext(ctx).ExecSQL(sql1, params1...); err != nil {
+//			return err
+//		}
+//		rows, err := gm.WithContext(ctx).QueryRow(sql2, params2...)
+//		...
+//	}, TxOptions{Isolation: sql.LevelRepeatableRead})
+func (gm *Gorm) Transaction(ctx context.Context, fn func(ctx context.Context) error, opts ...TxOptions) error {
+	if tc, ok := ctx.Value(txKey{}).(*txContext); ok && tc.opt == gm.Option {
+		return gm.WithTx(tc.tx).transaction(func(tx *gorm.DB) error {
+			return fn(context.WithValue(ctx, txKey{}, &txContext{opt: gm.Option, tx: tx}))
+		})
+	}
+	var sqlOpts []*sql.TxOptions
+	if len(opts) > 0 {
+		sqlOpts = append(sqlOpts, &sql.TxOptions{Isolation: opts[0].Isolation, ReadOnly: opts[0].ReadOnly})
+	}
+	return gm.withRetry(ctx, func() error {
+		return gm.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+			return fn(context.WithValue(ctx, txKey{}, &txContext{opt: gm.Option, tx: tx}))
+		}, sqlOpts...)
+	})
+}
+
+// WithContext 返回使用ctx的连接，ctx中有该连接的事务时加入事务
+func (gm *Gorm) WithContext(ctx context.Context) *Gorm {
+	if tc, ok := ctx.Value(txKey{}).(*txContext); ok && tc.opt == gm.Option {
+		return &Gorm{DB: tc.tx.WithContext(ctx), Option: gm.Option, retry: gm.retry, batch: gm.batch}
+	}
+	return &Gorm{DB: gm.DB.WithContext(ctx), Option: gm.Option, retry: gm.retry, batch: gm.batch}
+}
+
+// TxFromContext 返回ctx中的事务
+func TxFromContext(ctx context.Context) (*gorm.DB, bool) {
+	if tc, ok := ctx.Value(txKey{}).(*txContext); ok {
+		return tc.tx, true
+	}
+	return nil, false
+}
@@ -0,0 +1,109 @@
+package gorm
+
+import (
+	"context"
+	sqldriver "database/sql/driver"
+	"errors"
+	"gitops.sudytech.cn/guolei/gorm/driver"
+	gmysql "gorm.io/driver/mysql"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+	"strings"
+	"testing"
+)
+
+func TestGorm_WithContext(t *testing.T) {
+	newDB := func(id int) *gorm.DB {
+		return &gorm.DB{Config: &gorm.Config{CreateBatchSize: id}, Statement: &gorm.Statement{}}
+	}
+	gm := &Gorm{DB: newDB(1), Option: &Option{}}
+	other := &Gorm{DB: newDB(2), Option: &Option{}}
+	tx := newDB(3)
+	ctx := context.WithValue(context.Background(), txKey{}, &txContext{opt: gm.Option, tx: tx})
+	if got, ok := TxFromContext(ctx); !ok || got != tx {
+		t.Fatalf("tx must be stored in context")
+	}
+	if g := gm.WithContext(ctx); g.DB.CreateBatchSize != 3 || g.DB.Statement.Context != ctx || g.Option != gm.Option {
+		t.Fatalf("connection must join the context transaction")
+	}
+	if g := other.WithContext(ctx); g.DB.CreateBatchSize != 2 {
+		t.Fatalf("other connection must not join the transaction")
+	}
+	if _, ok := TxFromContext(context.Background()); ok {
+		t.Fatalf("background context must have no transaction")
+	}
+}
+
+// fakeSavePointer 记录保存点名称，可指定回滚到保存点时的错误
+type fakeSavePointer struct {
+	*gmysql.Dialector
+	points      []string
+	rollbacks   []string
+	rollbackErr error
+}
+
+func (d *fakeSavePointer) SavePoint(tx *gorm.DB, name string) error {
+	d.points = append(d.points, name)
+	return nil
+}
+
+func (d *fakeSavePointer) RollbackTo(tx *gorm.DB, name string) error {
+	d.rollbacks = append(d.rollbacks, name)
+	return d.rollbackErr
+}
+
+func TestGorm_NestedTransaction(t *testing.T) {
+	errBad := errors.New("bad sql")
+	gm, c := fakeGorm(t, func(query string, args []sqldriver.Value) (*fakeResult, error) {
+		if strings.HasPrefix(query, "BAD") {
+			return nil, errBad
+		}
+		return nil, nil
+	})
+	sp := &fakeSavePointer{Dialector: gm.DB.Dialector.(*gmysql.Dialector)}
+	db, err := gorm.Open(sp, &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatalf("open.err=%v", err)
+	}
+	gm.DB = db
+	err = gm.TransactionSQL([]TranSQL{{SQL: "UPDATE T_TEST SET a=1"}}, func(tx *gorm.DB, _ driver.Exp) error {
+		inner := gm.WithTx(tx)
+		if err := inner.TranSQL([]TranSQL{{SQL: "UPDATE T_TEST SET b=1"}}); err != nil {
+			return err
+		}
+		if err := inner.TranSQL([]TranSQL{{SQL: "BAD"}}); !errors.Is(err, errBad) {
+			t.Fatalf("inner err=%v", err)
+		}
+		if err := inner.TranSQL(nil, func() error { return nil }); err == nil {
+			t.Fatalf("nested callback must be rejected")
+		}
+		sp.rollbackErr = errors.New("lost")
+		if err := inner.TranSQL([]TranSQL{{SQL: "BAD"}}); !errors.Is(err, errBad) || !strings.Contains(err.Error(), "lost") {
+			t.Fatalf("rollback err=%v", err)
+		}
+		sp.rollbackErr = nil
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Fatalf("panic must be propagated")
+				}
+			}()
+			inner.transaction(func(tx *gorm.DB) error {
+				panic("inner")
+			})
+		}()
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("outer err=%v", err)
+	}
+	if len(sp.points) != 4 || sp.points[0] == sp.points[1] || !strings.HasPrefix(sp.points[0], "gorm_sp_") {
+		t.Fatalf("points=%v", sp.points)
+	}
+	if strings.Join(sp.rollbacks, ",") != strings.Join(sp.points[1:], ",") {
+		t.Fatalf("rollbacks=%v,points=%v", sp.rollbacks, sp.points)
+	}
+	if stmts := c.statements(); len(stmts) != 6 || stmts[0] != "BEGIN" || stmts[5] != "COMMIT" {
+		t.Fatalf("stmts=%v", stmts)
+	}
+}
@@ -0,0 +1,198 @@
+package gorm
+
+import (
+	"database/sql"
+	"fmt"
+	"github.com/spf13/cast"
+	"gitops.sudytech.cn/guolei/gorm/driver"
+	"gitops.sudytech.cn/guolei/gorm/sys"
+	"go.uber.org/zap"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// QueryAs 查询并按gorm column标签直接扫描为T，T为模型结构体，列名忽略大小写，未匹配的列忽略
+func QueryAs[T any](gm *Gorm, sql string, params ...interface{}) ([]T, error) {
+	return queryAs[T](gm, 0, 0, sql, params...)
+}
+
+// FindPageAs 同 FindPageList，记录扫描为T
+func FindPageAs[T any](gm *Gorm, pageNo int32, pageSize int32, cons []ConsWrapper, orders []QueryOrder) ([]T, int64, error) {
+	model := new(T)
+	return findPageList(pageNo, pageSize, func() (int64, error) {
+		return gm.FindPageTotal(model, cons)
+	}, func() ([]T, error) {
+		sql, params, err := gm.modelSQL(model, cons, orders)
+		if err != nil {
+			return nil, err
+		}
+		return queryAs[T](gm, pageNo, pageSize, sql, params...)
+	})
+}
+
+// GetByPk 按主键查询，pks顺序与模型主键字段顺序一致，不存在时返回nil
+func GetByPk[T any](gm *Gorm, pks ...interface{}) (*T, error) {
+	model := new(T)
+	props := sys.GetPks(model)
+	if len(props) == 0 || len(props) != len(pks) {
+		return nil, fmt.Errorf("%s主键个数为%d，参数个数为%d", tableName(model), len(props), len(pks))
+	}
+	var cons []ConsWrapper
+	for k, v := range props {
+		cons = append(cons, GenCons(v.GormP.Column, pks[k], CompareEqual))
+	}
+	sql, params, err := gm.modelSQL(model, cons, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := queryAs[T](gm, 0, 0, sql, params...)
+	if err != nil || len(resp) == 0 {
+		return nil, err
+	}
+	return &resp[0], nil
+}
+
+func queryAs[T any](gm *Gorm, pageNo int32, pageSize int32, sql string, params ...interface{}) ([]T, error) {
+	sql = pageSQL(sql, pageNo, pageSize)
+	exp := driver.Exp{DbType: gm.Option.DbType, Schema: gm.Option.Schema}
+	nSQL := exp.QuerySQL(sql)
+	rows, err := gm.DB.Raw(nSQL, params...).Rows()
+	if err != nil {
+		zap.S().Errorf("query as:src=%s,new=%s,param=%v,err=%v", sql, nSQL, params, err)
+		return nil, err
+	}
+	defer rows.Close()
+	resp, err := scanAs[T](rows)
+	if err != nil {
+		zap.S().Errorf("query as:src=%s,new=%s,param=%v,err=%v", sql, nSQL, params, err)
+	}
+	return resp, err
+}
+
+// scanAs 按列名匹配字段扫描结果集
+func scanAs[T any](rows *sql.Rows) ([]T, error) {
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+	fields := typedFields(new(T))
+	matched := make([]int, len(columns))
+	for k, v := range columns {
+		if index, ok := fields[strings.ToLower(v)]; ok {
+			matched[k] = index
+		} else {
+			matched[k] = -1
+		}
+	}
+	values := make([]interface{}, len(columns))
+	dest := make([]interface{}, len(columns))
+	for k := range values {
+		dest[k] = &values[k]
+	}
+	var resp []T
+	for rows.Next() {
+		if err = rows.Scan(dest...); err != nil {
+			return nil, err
+		}
+		var item T
+		rv := reflect.ValueOf(&item).Elem()
+		for k, index := range matched {
+			if index == -1 || values[k] == nil {
+				continue
+			}
+			if err = setTyped(rv.Field(index), values[k]); err != nil {
+				return nil, fmt.Errorf("%s:%v", columns[k], err)
+			}
+		}
+		resp = append(resp, item)
+	}
+	return resp, rows.Err()
+}
+
+// typedFields 小写列名到字段序号的映射，未设置column标签的字段不参与扫描
+func typedFields(model interface{}) map[string]int {
+	fields := make(map[string]int)
+	for k, v := range sys.GetTags(model) {
+		if g := v.GormP; g != nil && g.Column != "" {
+			fields[strings.ToLower(g.Column)] = k
+		}
+	}
+	return fields
+}
+
+var scannerType = reflect.TypeOf((*sql.Scanner)(nil)).Elem()
+
+// setTyped 将数据库值写入字段，bool与int按 toData 规则互转，时间字符串按 parseTime 解析
+func setTyped(field reflect.Value, value interface{}) error {
+	if b, ok := value.([]byte); ok {
+		value = string(b)
+	}
+	if field.CanAddr() && field.Addr().Type().Implements(scannerType) {
+		return field.Addr().Interface().(sql.Scanner).Scan(value)
+	}
+	if field.Kind() == reflect.Ptr {
+		elem := reflect.New(field.Type().Elem())
+		if err := setTyped(elem.Elem(), value); err != nil {
+			return err
+		}
+		field.Set(elem)
+		return nil
+	}
+	switch field.Kind() {
+	case reflect.Bool:
+		field.SetBool(intToBool(value))
+		return nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if v := fmt.Sprint(value); v == "true" || v == "false" {
+			value = boolToInt(value)
+		}
+		v, err := cast.ToInt64E(value)
+		if err != nil {
+			return err
+		}
+		field.SetInt(v)
+		return nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		v, err := cast.ToUint64E(value)
+		if err != nil {
+			return err
+		}
+		field.SetUint(v)
+		return nil
+	case reflect.Float32, reflect.Float64:
+		v, err := cast.ToFloat64E(value)
+		if err != nil {
+			return err
+		}
+		field.SetFloat(v)
+		return nil
+	case reflect.String:
+		if t, ok := value.(time.Time); ok {
+			field.SetString(t.Format(dateTimeFormatPattern))
+		} else {
+			field.SetString(cast.ToString(value))
+		}
+		return nil
+	}
+	if field.Type() == reflect.TypeOf(time.Time{}) {
+		switch value.(type) {
+		case time.Time:
+			field.Set(reflect.ValueOf(value))
+			return nil
+		case string:
+			t, err := parseTime(value.(string))
+			if err != nil || t == nil {
+				return err
+			}
+			field.Set(reflect.ValueOf(*t))
+			return nil
+		}
+	}
+	rv := reflect.ValueOf(value)
+	if rv.Type().ConvertibleTo(field.Type()) {
+		field.Set(rv.Convert(field.Type()))
+		return nil
+	}
+	return fmt.Errorf("%T无法转换为%s", value, field.Type())
+}
@@ -0,0 +1,101 @@
+package gorm
+
+import (
+	sqldriver "database/sql/driver"
+	"gitops.sudytech.cn/guolei/gorm/testdata"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSetTyped(t *testing.T) {
+	var alg testdata.Algorithm
+	rv := reflect.ValueOf(&alg).Elem()
+	fields := typedFields(&alg)
+	values := map[string]interface{}{
+		"id":         []byte("12"),
+		"name":       []byte("张"),
+		"type":       true,
+		"sort":       "false",
+		"audit":      int64(2),
+		"createTime": "2024-01-02 03:04:05",
+		"updateTime": time.Date(2024, 1, 3, 0, 0, 0, 0, time.Local),
+	}
+	for k, v := range values {
+		if err := setTyped(rv.Field(fields[strings.ToLower(k)]), v); err != nil {
+			t.Fatalf("%s.err=%v", k, err)
+		}
+	}
+	if alg.Id != 12 || alg.Name != "张" || alg.Type != 1 || alg.Sort != 0 || alg.Audit != 2 {
+		t.Fatalf("alg=%+v", alg)
+	}
+	if alg.CreateTime == nil || alg.CreateTime.Format(dateTimeFormatPattern) != "2024-01-02 03:04:05" || alg.UpdateTime.Day() != 3 {
+		t.Fatalf("createTime=%v,updateTime=%v", alg.CreateTime, alg.UpdateTime)
+	}
+	if err := setTyped(rv.Field(fields["id"]), "abc"); err == nil {
+		t.Fatalf("invalid int must be rejected")
+	}
+	var b struct{ Enable bool }
+	if setTyped(reflect.ValueOf(&b).Elem().Field(0), int64(1)); !b.Enable {
+		t.Fatalf("1 must be true")
+	}
+}
+
+func TestTypedFields(t *testing.T) {
+	fields := typedFields(&testdata.Algorithm{})
+	if len(fields) != 12 || fields["id"] != 0 || fields["createtime"] != 11 {
+		t.Fatalf("fields=%v", fields)
+	}
+	var item struct {
+		Id   int64 `gorm:"column:id"`
+		Temp string
+		Name string `gorm:"column:NAME"`
+	}
+	if fields = typedFields(&item); len(fields) != 2 || fields["name"] != 2 {
+		t.Fatalf("fields=%v", fields)
+	}
+}
+
+func TestGetByPk_SQL(t *testing.T) {
+	gm, c := fakeGorm(t, func(query string, args []sqldriver.Value) (*fakeResult, error) {
+		if len(args) == 1 && args[0] == int64(5) {
+			return &fakeResult{columns: []string{"ID", "code", "unknown"}, rows: [][]sqldriver.Value{{int64(5), []byte("a"), "x"}}}, nil
+		}
+		return &fakeResult{columns: []string{"id"}}, nil
+	})
+	alg, err := GetByPk[testdata.Algorithm](gm, 5)
+	if err != nil || alg == nil || alg.Id != 5 || alg.Code != "a" {
+		t.Fatalf("alg=%+v,err=%v", alg, err)
+	}
+	if stmts := c.statements(); len(stmts) != 1 || stmts[0] != "SELECT t.* FROM T_TEST_ALGORITHM t WHERE 1=1 AND (id = ?)" {
+		t.Fatalf("stmts=%v", stmts)
+	}
+	if alg, err = GetByPk[testdata.Algorithm](gm, 6); err != nil || alg != nil {
+		t.Fatalf("missing alg=%+v,err=%v", alg, err)
+	}
+	if _, err = GetByPk[testdata.Algorithm](gm, 1, 2); err == nil {
+		t.Fatalf("pk count must match")
+	}
+}
+
+func TestFindPageAs(t *testing.T) {
+	gm, c := fakeGorm(t, func(query string, args []sqldriver.Value) (*fakeResult, error) {
+		if strings.HasPrefix(query, "SELECT COUNT(1)") {
+			return &fakeResult{columns: []string{"COUNT(1)"}, rows: [][]sqldriver.Value{{int64(3)}}}, nil
+		}
+		return &fakeResult{columns: []string{"id"}, rows: [][]sqldriver.Value{{int64(2)}}}, nil
+	})
+	algs, count, err := FindPageAs[testdata.Algorithm](gm, 2, 1, []ConsWrapper{GenCons("type", 1, CompareEqual)}, []QueryOrder{{FieldName: "id"}})
+	if err != nil || count != 3 || len(algs) != 1 || algs[0].Id != 2 {
+		t.Fatalf("algs=%+v,count=%d,err=%v", algs, count, err)
+	}
+	stmts := strings.Join(c.statements(), "\n")
+	if !strings.Contains(stmts, "SELECT COUNT(1) FROM T_TEST_ALGORITHM t WHERE 1=1 AND (type = ?)") ||
+		!strings.Contains(stmts, "SELECT t.* FROM T_TEST_ALGORITHM t WHERE 1=1 AND (type = ?) ORDER BY id DESC LIMIT 1,1") {
+		t.Fatalf("stmts=%s", stmts)
+	}
+	if _, _, err = FindPageAs[testdata.Algorithm](gm, 1, 1, []ConsWrapper{GenNot(nil)}, nil); err == nil {
+		t.Fatalf("invalid cons must be rejected")
+	}
+}